@@ -3,13 +3,15 @@
 package backend
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"errors"
+	"fmt"
 	"io"
 	"time"
 
-	btree "github.com/boltdb/bolt"
 	"github.com/golang/snappy"
+	btree "go.etcd.io/bbolt"
 )
 
 // Backend represents a persistent AzmoDB backend.
@@ -35,8 +37,12 @@ type Batch interface {
 	// copy of the supplied key and value.
 	Put(key []byte, value []byte) error
 
-	// Close closes the batch transaction.
+	// Close commits and closes the batch transaction.
 	Close() error
+
+	// Rollback closes the batch transaction and discards all previous
+	// writes. The revision of a rolled back batch must not be visible.
+	Rollback() error
 }
 
 // Revision represents a serialized AzmoDB revision.
@@ -166,10 +172,10 @@ func (db *DB) Range(rev Revision, fn func(key, value []byte) error) error {
 				return err
 			}
 			if err = fn(safeKey, safeValue); err != nil {
-				break
+				return err
 			}
 		}
-		return err
+		return nil
 	})
 }
 
@@ -184,6 +190,55 @@ func (db *DB) Last() (rev Revision, err error) {
 	return rev, err
 }
 
+// Revisions returns all revisions in the database in ascending order.
+func (db *DB) Revisions() (revs []Revision, err error) {
+	err = db.root.View(func(tx *btree.Tx) error {
+		return tx.Bucket(metaBucket).ForEach(func(k, _ []byte) error {
+			var rev Revision
+			copy(rev[:], k)
+			revs = append(revs, rev)
+			return nil
+		})
+	})
+	return revs, err
+}
+
+// Verify checks the integrity of the database. Every key of every
+// revision must reference a stored value, every stored value must
+// match its checksum and decode. Verify returns the first violation
+// found.
+func (db *DB) Verify() error {
+	return db.root.View(func(tx *btree.Tx) error {
+		data := tx.Bucket(dataBucket)
+		err := data.ForEach(func(sum, v []byte) error {
+			if want := sha1sum(v); !bytes.Equal(sum, want[:]) {
+				return fmt.Errorf("value %x: checksum mismatch", sum)
+			}
+			if _, err := snappy.Decode(nil, v); err != nil {
+				return fmt.Errorf("value %x: %v", sum, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		meta := tx.Bucket(metaBucket)
+		return meta.ForEach(func(rev, _ []byte) error {
+			b := meta.Bucket(rev)
+			if b == nil || len(rev) != len(Revision{}) {
+				return fmt.Errorf("revision %x: malformed revision", rev)
+			}
+			return b.ForEach(func(k, sum []byte) error {
+				if data.Get(sum) == nil {
+					return fmt.Errorf("revision %x: key %q: value not found", rev, k)
+				}
+				return nil
+			})
+		})
+	})
+}
+
 // Batch starts a new batch transaction. Starting multiple write batch
 // transactions will cause the calls to block and be serialized until
 // the current write batch transaction finishes.
@@ -196,6 +251,7 @@ func (db *DB) Batch(rev Revision) (Batch, error) {
 	meta, data := tx.Bucket(metaBucket), tx.Bucket(dataBucket)
 	meta, err = meta.CreateBucket(rev[:])
 	if err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 	return &batch{
@@ -287,6 +343,10 @@ func (b *batch) Close() error {
 	return b.tx.Commit()
 }
 
+func (b *batch) Rollback() error {
+	return b.tx.Rollback()
+}
+
 func clone(dst, src []byte) []byte {
 	n := len(src)
 	if len(dst) < n {
@@ -7,7 +7,7 @@ import (
 	"os"
 	"testing"
 
-	btree "github.com/boltdb/bolt"
+	btree "go.etcd.io/bbolt"
 )
 
 func testDefaultBackend(t *testing.T, db *DB) {
@@ -118,3 +118,41 @@ func TestDuplicateValues(t *testing.T) {
 		t.Fatalf("backend: expected %d entries, have %d", count, i)
 	}
 }
+
+func TestRevisionsAndVerify(t *testing.T) {
+	db, err := Open("test_verify.db", 0)
+	if err != nil {
+		t.Fatalf("open default database: %v", err)
+	}
+	defer func() {
+		db.Close()
+		os.RemoveAll("test_verify.db")
+	}()
+
+	insertEntries(t, 10, 1, db)
+	insertEntries(t, 10, 3, db)
+
+	revs, err := db.Revisions()
+	if err != nil {
+		t.Fatalf("revisions: %v", err)
+	}
+	if len(revs) != 2 || revs[0][7] != 1 || revs[1][7] != 3 {
+		t.Fatalf("revisions: expected [1 3], have %v", revs)
+	}
+	if err = db.Verify(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// corrupt a stored value
+	err = db.root.Update(func(tx *btree.Tx) error {
+		data := tx.Bucket(dataBucket)
+		k, v := data.Cursor().First()
+		return data.Put(k, append(clone(nil, v), 0))
+	})
+	if err != nil {
+		t.Fatalf("corrupt data bucket: %v", err)
+	}
+	if err = db.Verify(); err == nil {
+		t.Fatalf("verify: expected checksum error")
+	}
+}
@@ -0,0 +1,297 @@
+// Package backendtest implements a conformance test suite for
+// backend.Backend implementations.
+//
+// The suite checks the semantics the AzmoDB database relies on when
+// taking and reloading snapshots. A third-party backend is verified by
+// calling Run from an ordinary test function:
+//
+//	func TestConformance(t *testing.T) {
+//		backendtest.Run(t, func(t *testing.T) (backend.Backend, func()) {
+//			b := mybackend.New()
+//			return b, func() { b.Close() }
+//		})
+//	}
+package backendtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/azmodb/db/backend"
+)
+
+// Factory returns a new, empty backend and a function releasing all
+// resources held by the backend. Factory is called once for every test
+// in the suite.
+type Factory func(t *testing.T) (backend.Backend, func())
+
+type test struct {
+	name string
+	fn   func(t *testing.T, b backend.Backend)
+}
+
+var tests = []test{
+	{"LastEmpty", testLastEmpty},
+	{"LastHighest", testLastHighest},
+	{"RangeOrder", testRangeOrder},
+	{"RangeEmptyBatch", testRangeEmptyBatch},
+	{"RangeRevisionNotFound", testRangeRevisionNotFound},
+	{"RangeStop", testRangeStop},
+	{"RangeIsolation", testRangeIsolation},
+	{"PutCopy", testPutCopy},
+	{"DuplicateRevision", testDuplicateRevision},
+	{"Rollback", testRollback},
+}
+
+// Run runs the conformance test suite against backends returned by
+// factory. Every test runs as a subtest named after the tested
+// property, so that single tests can be selected with -run.
+func Run(t *testing.T, factory Factory) {
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, release := factory(t)
+			defer release()
+			test.fn(t, b)
+		})
+	}
+}
+
+// Revision returns the serialized representation of rev.
+func Revision(rev uint64) backend.Revision {
+	r := backend.Revision{}
+	binary.BigEndian.PutUint64(r[:], rev)
+	return r
+}
+
+func key(i int) []byte   { return []byte(fmt.Sprintf("k%.4d", i)) }
+func value(i int) []byte { return []byte(fmt.Sprintf("v%.4d", i)) }
+
+// write writes count key/value pairs in reverse key order at rev.
+func write(t *testing.T, b backend.Backend, rev uint64, count int) {
+	batch, err := b.Batch(Revision(rev))
+	if err != nil {
+		t.Fatalf("batch %d: %v", rev, err)
+	}
+	for i := count - 1; i >= 0; i-- {
+		if err = batch.Put(key(i), value(i)); err != nil {
+			batch.Close()
+			t.Fatalf("batch %d: put: %v", rev, err)
+		}
+	}
+	if err = batch.Close(); err != nil {
+		t.Fatalf("batch %d: close: %v", rev, err)
+	}
+}
+
+// verify checks that rev contains exactly count ordered key/value
+// pairs as written by write.
+func verify(t *testing.T, b backend.Backend, rev uint64, count int) {
+	i := 0
+	err := b.Range(Revision(rev), func(k, v []byte) error {
+		if i >= count {
+			return fmt.Errorf("unexpected key %q", k)
+		}
+		if !bytes.Equal(k, key(i)) {
+			return fmt.Errorf("expected key %q, have %q", key(i), k)
+		}
+		if !bytes.Equal(v, value(i)) {
+			return fmt.Errorf("expected value %q, have %q", value(i), v)
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("range %d: %v", rev, err)
+	}
+	if i != count {
+		t.Fatalf("range %d: expected %d pairs, have %d", rev, count, i)
+	}
+}
+
+func testLastEmpty(t *testing.T, b backend.Backend) {
+	rev, err := b.Last()
+	if err != nil {
+		t.Fatalf("last: empty backend returned error: %v", err)
+	}
+	if rev != (backend.Revision{}) {
+		t.Fatalf("last: empty backend expected zero revision, have %v", rev)
+	}
+}
+
+func testLastHighest(t *testing.T, b backend.Backend) {
+	for _, rev := range []uint64{2, 1 << 40, 3, 1 << 8} {
+		write(t, b, rev, 1)
+	}
+
+	rev, err := b.Last()
+	if err != nil {
+		t.Fatalf("last: %v", err)
+	}
+	if want := Revision(1 << 40); rev != want {
+		t.Fatalf("last: expected revision %v, have %v", want, rev)
+	}
+}
+
+func testRangeOrder(t *testing.T, b backend.Backend) {
+	write(t, b, 1, 1000)
+	write(t, b, 2, 10)
+
+	verify(t, b, 1, 1000)
+	verify(t, b, 2, 10)
+}
+
+func testRangeEmptyBatch(t *testing.T, b backend.Backend) {
+	write(t, b, 1, 0)
+
+	verify(t, b, 1, 0)
+	rev, err := b.Last()
+	if err != nil {
+		t.Fatalf("last: %v", err)
+	}
+	if want := Revision(1); rev != want {
+		t.Fatalf("last: expected revision %v, have %v", want, rev)
+	}
+}
+
+func testRangeRevisionNotFound(t *testing.T, b backend.Backend) {
+	if err := b.Range(Revision(1), func(k, v []byte) error {
+		return nil
+	}); err == nil {
+		t.Fatalf("range: empty backend expected revision not found error")
+	}
+
+	write(t, b, 1, 1)
+	if err := b.Range(Revision(2), func(k, v []byte) error {
+		return nil
+	}); err == nil {
+		t.Fatalf("range: expected revision not found error")
+	}
+}
+
+func testRangeStop(t *testing.T, b backend.Backend) {
+	write(t, b, 1, 10)
+
+	errStop := errors.New("stop")
+	i := 0
+	err := b.Range(Revision(1), func(k, v []byte) error {
+		i++
+		if i == 5 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Fatalf("range: expected error %v, have %v", errStop, err)
+	}
+	if i != 5 {
+		t.Fatalf("range: expected traversal to stop after 5 pairs, have %d", i)
+	}
+}
+
+func testRangeIsolation(t *testing.T, b backend.Backend) {
+	write(t, b, 1, 10)
+
+	err := b.Range(Revision(1), func(k, v []byte) error {
+		for i := range k {
+			k[i] = 0
+		}
+		for i := range v {
+			v[i] = 0
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("range: %v", err)
+	}
+	verify(t, b, 1, 10)
+}
+
+func testPutCopy(t *testing.T, b backend.Backend) {
+	batch, err := b.Batch(Revision(1))
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+
+	k, v := make([]byte, 5), make([]byte, 5)
+	for i := 9; i >= 0; i-- {
+		copy(k, key(i))
+		copy(v, value(i))
+		if err = batch.Put(k, v); err != nil {
+			batch.Close()
+			t.Fatalf("put: %v", err)
+		}
+	}
+	if err = batch.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	verify(t, b, 1, 10)
+}
+
+// testDuplicateRevision checks that a revision cannot be written twice.
+// The backend must reject the second batch either when it is started
+// or when it is closed, and the failed batch must leave the existing
+// revision untouched.
+func testDuplicateRevision(t *testing.T, b backend.Backend) {
+	write(t, b, 1, 10)
+
+	batch, err := b.Batch(Revision(1))
+	if err == nil {
+		for i := 0; i < 20; i++ {
+			if err = batch.Put(key(i), []byte("overwritten")); err != nil {
+				break
+			}
+		}
+		if cerr := batch.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err == nil {
+		t.Fatalf("duplicate revision: expected error, have <nil>")
+	}
+
+	verify(t, b, 1, 10)
+	rev, err := b.Last()
+	if err != nil {
+		t.Fatalf("last: %v", err)
+	}
+	if want := Revision(1); rev != want {
+		t.Fatalf("last: expected revision %v, have %v", want, rev)
+	}
+}
+
+func testRollback(t *testing.T, b backend.Backend) {
+	write(t, b, 1, 10)
+
+	batch, err := b.Batch(Revision(2))
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err = batch.Put(key(i), value(i)); err != nil {
+			batch.Rollback()
+			t.Fatalf("put: %v", err)
+		}
+	}
+	if err = batch.Rollback(); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+
+	rev, err := b.Last()
+	if err != nil {
+		t.Fatalf("last: %v", err)
+	}
+	if want := Revision(1); rev != want {
+		t.Fatalf("last: expected revision %v, have %v", want, rev)
+	}
+	if err = b.Range(Revision(2), func(k, v []byte) error {
+		return nil
+	}); err == nil {
+		t.Fatalf("range: rolled back revision is visible")
+	}
+
+	write(t, b, 2, 5)
+	verify(t, b, 2, 5)
+}
@@ -0,0 +1,179 @@
+package backendtest
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/azmodb/db/backend"
+)
+
+var (
+	// ErrInjected is returned by a Faulty backend when an injected
+	// fault is triggered.
+	ErrInjected = errors.New("backendtest: injected fault")
+
+	// ErrCrashed is returned by every operation on a Faulty backend
+	// after a simulated crash.
+	ErrCrashed = errors.New("backendtest: backend crashed")
+)
+
+// Fault describes the faults a Faulty backend injects.
+type Fault struct {
+	// Put fails the Nth call to Put, counted from one across all
+	// batches. Zero disables the fault.
+	Put int
+
+	// Close fails every call to Close.
+	Close bool
+
+	// Range fails Range after N key/value pairs have been passed to
+	// the traversal function. Zero disables the fault.
+	Range int
+
+	// Crash simulates a crash instead of returning ErrInjected. The
+	// pending batch is abandoned, as if the process died, and all
+	// subsequent operations return ErrCrashed.
+	Crash bool
+}
+
+// Faulty is a backend wrapper injecting errors or simulated crashes
+// into an underlying backend.
+type Faulty struct {
+	mu      sync.Mutex
+	b       backend.Backend
+	fault   Fault
+	puts    int
+	crashed bool
+}
+
+var _ backend.Backend = (*Faulty)(nil)
+
+// NewFaulty returns a backend injecting fault into b.
+func NewFaulty(b backend.Backend, fault Fault) *Faulty {
+	return &Faulty{b: b, fault: fault}
+}
+
+// Crashed reports whether a simulated crash occured.
+func (f *Faulty) Crashed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.crashed
+}
+
+// fail returns the error of a triggered fault and marks the backend
+// as crashed if requested.
+func (f *Faulty) fail() error {
+	if f.fault.Crash {
+		f.crashed = true
+		return ErrCrashed
+	}
+	return ErrInjected
+}
+
+// Range implements the backend.Backend interface.
+func (f *Faulty) Range(rev backend.Revision, fn func(key, value []byte) error) error {
+	f.mu.Lock()
+	if f.crashed {
+		f.mu.Unlock()
+		return ErrCrashed
+	}
+	limit := f.fault.Range
+	f.mu.Unlock()
+
+	n := 0
+	return f.b.Range(rev, func(key, value []byte) error {
+		if limit > 0 && n >= limit {
+			f.mu.Lock()
+			err := f.fail()
+			f.mu.Unlock()
+			return err
+		}
+		n++
+		return fn(key, value)
+	})
+}
+
+// Batch implements the backend.Backend interface.
+func (f *Faulty) Batch(rev backend.Revision) (backend.Batch, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.crashed {
+		return nil, ErrCrashed
+	}
+
+	b, err := f.b.Batch(rev)
+	if err != nil {
+		return nil, err
+	}
+	return &faultyBatch{b: b, f: f}, nil
+}
+
+// Last implements the backend.Backend interface.
+func (f *Faulty) Last() (backend.Revision, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.crashed {
+		return backend.Revision{}, ErrCrashed
+	}
+	return f.b.Last()
+}
+
+type faultyBatch struct {
+	b      backend.Batch
+	f      *Faulty
+	closed bool
+}
+
+// abandon releases the underlying batch without committing it.
+func (b *faultyBatch) abandon() {
+	if !b.closed {
+		b.b.Rollback()
+		b.closed = true
+	}
+}
+
+func (b *faultyBatch) Put(key, value []byte) error {
+	b.f.mu.Lock()
+	defer b.f.mu.Unlock()
+	if b.f.crashed {
+		b.abandon()
+		return ErrCrashed
+	}
+
+	b.f.puts++
+	if b.f.fault.Put > 0 && b.f.puts == b.f.fault.Put {
+		err := b.f.fail()
+		if err == ErrCrashed {
+			b.abandon()
+		}
+		return err
+	}
+	return b.b.Put(key, value)
+}
+
+func (b *faultyBatch) Close() error {
+	b.f.mu.Lock()
+	defer b.f.mu.Unlock()
+	if b.f.crashed || b.f.fault.Close {
+		var err error = ErrCrashed
+		if !b.f.crashed {
+			err = b.f.fail()
+		}
+		b.abandon()
+		return err
+	}
+
+	b.closed = true
+	return b.b.Close()
+}
+
+func (b *faultyBatch) Rollback() error {
+	b.f.mu.Lock()
+	defer b.f.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+
+	b.closed = true
+	return b.b.Rollback()
+}
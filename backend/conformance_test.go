@@ -0,0 +1,22 @@
+package backend_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/azmodb/db/backend"
+	"github.com/azmodb/db/backend/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) (backend.Backend, func()) {
+		db, err := backend.Open("test_conformance.db", 0)
+		if err != nil {
+			t.Fatalf("open default database: %v", err)
+		}
+		return db, func() {
+			db.Close()
+			os.RemoveAll("test_conformance.db")
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package db
+
+import "context"
+
+// Change represents a single key/value update of a committed
+// transaction.
+type Change struct {
+	Key     []byte
+	Data    interface{}
+	Rev     int64
+	Deleted bool
+}
+
+// Commit represents a committed transaction over the interval of
+// revisions [From, To]. Changes are ordered by revision.
+//
+// When a follower catches up from a snapshot, the transactions covered
+// by the snapshot are reported as a single Commit holding the net
+// changes. Deletions are reported at revision To in that case.
+type Commit struct {
+	From    int64
+	To      int64
+	Changes []Change
+}
+
+// Changefeed delivers committed transactions of a database as a whole,
+// in revision order.
+type Changefeed struct {
+	db *DB
+	n  *Notifier
+}
+
+// Changefeed returns a feed of all transactions committed after the
+// returned revision. The feed is canceled when ctx is done.
+//
+// A Changefeed supports the Block and CancelSlow overflow policies.
+// Any other policy, including the zero value, would silently lose
+// transactions and is treated as CancelSlow.
+func (db *DB) Changefeed(ctx context.Context, opts NotifierOptions) (*Changefeed, int64) {
+	if opts.Policy != Block {
+		opts.Policy = CancelSlow
+	}
+	f := &Changefeed{db: db}
+
+	db.writer.Lock()
+	f.n = newNotifier(ctx, 42, f.cancel, opts)
+	db.hooks = append(db.hooks, f)
+	rev := db.Rev()
+	db.writer.Unlock()
+	return f, rev
+}
+
+func newCommit(rec record) Commit {
+	c := Commit{
+		From:    rec.From,
+		To:      rec.To,
+		Changes: make([]Change, 0, len(rec.Changes)),
+	}
+	for _, ch := range rec.Changes {
+		c.Changes = append(c.Changes, Change{
+			Key:     ch.Key,
+			Data:    ch.Data,
+			Rev:     ch.Rev,
+			Deleted: ch.Deleted,
+		})
+	}
+	return c
+}
+
+// committed implements the observer interface.
+func (f *Changefeed) committed(rec record) {
+	c := newCommit(rec)
+	if !f.n.send(Event{Data: c, Created: c.From, Current: c.To}) {
+		f.db.removeHook(f) // canceled or slow consumer
+	}
+}
+
+// replaced implements the observer interface.
+func (f *Changefeed) replaced(rec record) { f.committed(rec) }
+
+func (f *Changefeed) cancel(_ *Notifier) {
+	f.db.writer.Lock()
+	f.db.removeHook(f)
+	f.db.writer.Unlock()
+}
+
+// Next waits for the next committed transaction. It returns the context
+// error if ctx is done first, ErrSlowConsumer if the feed has fallen
+// behind and NotifierCanceled after the feed has been canceled.
+func (f *Changefeed) Next(ctx context.Context) (Commit, error) {
+	ev, err := f.n.Next(ctx)
+	if err != nil {
+		return Commit{}, err
+	}
+	return ev.Data.(Commit), nil
+}
+
+// Stats returns the current queue statistics of the feed.
+func (f *Changefeed) Stats() NotifierStats { return f.n.Stats() }
+
+// Cancel cancels and closes the feed. It should not be reused.
+func (f *Changefeed) Cancel() { f.n.Cancel() }
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestChangefeed(t *testing.T) {
+	db := New()
+	tx := db.Txn()
+	tx.Put([]byte("a"), 1, false)
+	tx.Commit()
+
+	f, rev := db.Changefeed(context.Background(), NotifierOptions{})
+	if rev != 1 {
+		t.Fatalf("changefeed: expected revision 1, have %d", rev)
+	}
+
+	tx = db.Txn()
+	tx.Put([]byte("b"), 2, false)
+	tx.Delete([]byte("a"))
+	tx.Commit()
+	tx = db.Txn()
+	tx.Put([]byte("c"), 3, false)
+	tx.Rollback()
+	tx = db.Txn()
+	tx.Put([]byte("c"), 3, false)
+	tx.Commit()
+
+	for i, want := range []Commit{
+		{From: 2, To: 3, Changes: []Change{
+			{Key: []byte("b"), Data: 2, Rev: 2},
+			{Key: []byte("a"), Rev: 3, Deleted: true},
+		}},
+		{From: 4, To: 4, Changes: []Change{
+			{Key: []byte("c"), Data: 3, Rev: 4},
+		}},
+	} {
+		c, err := f.Next(context.Background())
+		if err != nil {
+			t.Fatalf("changefeed #%d: %v", i, err)
+		}
+		if c.From != want.From || c.To != want.To || len(c.Changes) != len(want.Changes) {
+			t.Fatalf("changefeed #%d: expected %+v, have %+v", i, want, c)
+		}
+		for j, ch := range c.Changes {
+			w := want.Changes[j]
+			if string(ch.Key) != string(w.Key) || ch.Data != w.Data || ch.Rev != w.Rev || ch.Deleted != w.Deleted {
+				t.Fatalf("changefeed #%d: expected change %+v, have %+v", i, w, ch)
+			}
+		}
+	}
+
+	f.Cancel()
+	if _, err := f.Next(context.Background()); err != NotifierCanceled {
+		t.Fatalf("changefeed: expected error %v, have %v", NotifierCanceled, err)
+	}
+	if len(db.hooks) != 0 {
+		t.Fatalf("changefeed: expected no commit observers, have %d", len(db.hooks))
+	}
+}
+
+func TestChangefeedSlowConsumer(t *testing.T) {
+	db := New()
+	f, _ := db.Changefeed(context.Background(), NotifierOptions{Capacity: 1, Policy: DropOldest})
+	for i := 0; i < 4; i++ {
+		tx := db.Txn()
+		tx.Put([]byte("k"), i, false)
+		tx.Commit()
+	}
+
+	var err error
+	for err == nil {
+		_, err = f.Next(context.Background())
+	}
+	if err != ErrSlowConsumer {
+		t.Fatalf("changefeed: expected error %v, have %v", ErrSlowConsumer, err)
+	}
+	if len(db.hooks) != 0 {
+		t.Fatalf("changefeed: expected no commit observers, have %d", len(db.hooks))
+	}
+}
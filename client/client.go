@@ -0,0 +1,273 @@
+// Package client implements a Go client for the AzmoDB gRPC API served
+// by package rpc.
+//
+// The client mirrors the method set of *db.DB. Because remote calls can
+// fail, methods which cannot fail in-process additionally return an
+// error. Values can be []byte, string, bool or any integer or float
+// type except uintptr; numeric values keep their Go type.
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/azmodb/db"
+	"github.com/azmodb/db/rpcpb"
+	"google.golang.org/grpc"
+)
+
+// Client represents a connection to a remote database.
+type Client struct {
+	rpc  rpcpb.DBClient
+	conn *grpc.ClientConn // owned connection, nil if supplied by caller
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex // protects the fields below
+	watch    rpcpb.DB_WatchClient
+	watchers map[int64]*watcher
+	nextID   int64
+}
+
+// New returns a client using the supplied gRPC connection. The
+// connection is not closed by Close.
+func New(conn grpc.ClientConnInterface) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Client{
+		rpc:      rpcpb.NewDBClient(conn),
+		ctx:      ctx,
+		cancel:   cancel,
+		watchers: make(map[int64]*watcher),
+	}
+}
+
+// Dial creates a connection to the database server at target and
+// returns a client using it.
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c := New(conn)
+	c.conn = conn
+	return c, nil
+}
+
+// Close cancels all notifiers and running transactions of the client
+// and closes the connection if it has been created by Dial.
+func (c *Client) Close() error {
+	c.cancel()
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
+
+// Get retrieves the value for a key at revision rev. If rev <= 0 it
+// returns the current value for a key. If equal is true the value
+// revision must match the supplied rev.
+//
+// Get returns the revision of the key/value pair, the current revision
+// of the database and an errors if any.
+func (c *Client) Get(key []byte, rev int64, equal bool) (interface{}, int64, int64, error) {
+	resp, err := c.rpc.Get(c.ctx, &rpcpb.GetRequest{
+		Key:   key,
+		Rev:   rev,
+		Equal: equal,
+	})
+	if err != nil {
+		return nil, 0, 0, rpcpb.FromStatus(err)
+	}
+	return resp.Value.Data(), resp.Created, resp.Current, nil
+}
+
+// Range iterates over values stored in the database in the range at rev
+// over the interval [from, to] from left to right. See DB.Range for
+// details.
+//
+// The events are received over a stream which applies flow control, so
+// the server waits for a consumer which does not keep up.
+//
+// Range returns a notifier, the current revision of the database and an
+// error if any.
+func (c *Client) Range(from, to []byte, rev int64, limit int32) (*Notifier, int64, error) {
+	ctx, cancel := context.WithCancel(c.ctx)
+	stream, err := c.rpc.Range(ctx, &rpcpb.RangeRequest{
+		From:  from,
+		To:    to,
+		Rev:   rev,
+		Limit: limit,
+	})
+	if err != nil {
+		cancel()
+		return nil, 0, rpcpb.FromStatus(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		cancel()
+		return nil, 0, rpcpb.FromStatus(err)
+	}
+
+	n := newNotifier(cancel, NotifierOptions{Policy: db.Block})
+	go func() {
+		defer cancel()
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				n.close(db.NotifierCanceled)
+				return
+			}
+			if !n.push(newEvent(resp.Event)) {
+				return
+			}
+		}
+	}()
+	return n, resp.Current, nil
+}
+
+// Rev returns the current revision of the database.
+func (c *Client) Rev() (int64, error) {
+	resp, err := c.rpc.Rev(c.ctx, &rpcpb.RevRequest{})
+	if err != nil {
+		return 0, rpcpb.FromStatus(err)
+	}
+	return resp.Rev, nil
+}
+
+// watcher represents a watcher registered on the watch stream.
+type watcher struct {
+	n       *Notifier
+	created chan *rpcpb.WatchResponse
+}
+
+// Watch returns a notifier for a key. If the key does not exist it
+// returns an error. All watchers of a client share a single
+// bidirectional stream.
+func (c *Client) Watch(key []byte) (*Notifier, int64, error) {
+	return c.WatchWith(key, NotifierOptions{})
+}
+
+// WatchWith is like Watch but configures the local queue of the
+// notifier.
+func (c *Client) WatchWith(key []byte, opts NotifierOptions) (*Notifier, int64, error) {
+	c.mu.Lock()
+	if c.watch == nil {
+		stream, err := c.rpc.Watch(c.ctx)
+		if err != nil {
+			c.mu.Unlock()
+			return nil, 0, rpcpb.FromStatus(err)
+		}
+		c.watch = stream
+		go c.dispatch(stream)
+	}
+
+	c.nextID++
+	id, stream := c.nextID, c.watch
+	w := &watcher{created: make(chan *rpcpb.WatchResponse, 1)}
+	w.n = newNotifier(func() { c.cancelWatch(stream, id) }, opts)
+	c.watchers[id] = w
+	err := stream.Send(&rpcpb.WatchRequest{
+		Request: &rpcpb.WatchRequest_Create{
+			Create: &rpcpb.WatchCreate{WatchId: id, Key: key},
+		},
+	})
+	c.mu.Unlock()
+	if err != nil {
+		c.removeWatcher(id)
+		return nil, 0, err
+	}
+
+	resp, ok := <-w.created
+	if !ok {
+		return nil, 0, db.NotifierCanceled
+	}
+	if resp.Error != "" {
+		c.removeWatcher(id)
+		return nil, resp.Current, rpcpb.Error(resp.Error)
+	}
+	return w.n, resp.Current, nil
+}
+
+func (c *Client) removeWatcher(id int64) {
+	c.mu.Lock()
+	delete(c.watchers, id)
+	c.mu.Unlock()
+}
+
+func (c *Client) cancelWatch(stream rpcpb.DB_WatchClient, id int64) {
+	c.mu.Lock()
+	w, found := c.watchers[id]
+	if !found || c.watch != stream {
+		c.mu.Unlock()
+		return
+	}
+	err := stream.Send(&rpcpb.WatchRequest{
+		Request: &rpcpb.WatchRequest_Cancel{
+			Cancel: &rpcpb.WatchCancel{WatchId: id},
+		},
+	})
+	if err != nil {
+		delete(c.watchers, id)
+	}
+	c.mu.Unlock()
+
+	// The server acknowledges the cancellation with a final event. If
+	// the request could not be sent, close the notifier locally.
+	if err != nil {
+		w.n.close(db.NotifierCanceled)
+	}
+}
+
+// dispatch delivers the responses of the watch stream to the
+// registered watchers.
+func (c *Client) dispatch(stream rpcpb.DB_WatchClient) {
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			break
+		}
+
+		c.mu.Lock()
+		w, found := c.watchers[resp.WatchId]
+		if found && !resp.Created && resp.Event.GetError() != "" {
+			delete(c.watchers, resp.WatchId)
+		}
+		c.mu.Unlock()
+		if !found {
+			continue
+		}
+
+		if resp.Created {
+			w.created <- resp
+			continue
+		}
+		w.n.push(newEvent(resp.Event))
+	}
+
+	// The stream failed, close all watchers. The next call to Watch
+	// opens a new stream.
+	c.mu.Lock()
+	watchers := c.watchers
+	c.watchers = make(map[int64]*watcher)
+	if c.watch == stream {
+		c.watch = nil
+	}
+	c.mu.Unlock()
+	for _, w := range watchers {
+		close(w.created)
+		w.n.close(db.NotifierCanceled)
+	}
+}
+
+func newEvent(ev *rpcpb.Event) Event {
+	if ev.GetError() != "" {
+		return Event{err: rpcpb.Error(ev.Error)}
+	}
+	return Event{
+		Data:    ev.Value.Data(),
+		Created: ev.Created,
+		Current: ev.Current,
+		Key:     ev.Key,
+	}
+}
@@ -0,0 +1,342 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/azmodb/db"
+	"github.com/azmodb/db/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func newTestClient(t *testing.T) (*Client, *db.DB, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	d := db.New()
+	srv := grpc.NewServer()
+	rpc.Register(srv, d)
+	go srv.Serve(ln)
+
+	c, err := Dial(ln.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	return c, d, func() {
+		c.Close()
+		srv.Stop()
+	}
+}
+
+func putKeys(t *testing.T, c *Client, n int) int64 {
+	tx, err := c.Txn()
+	if err != nil {
+		t.Fatalf("txn: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("k%.3d", i))
+		if _, err = tx.Put(key, int64(i), false); err != nil {
+			t.Fatalf("put %q: %v", key, err)
+		}
+	}
+	rev, err := tx.Commit()
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	return rev
+}
+
+func TestClient(t *testing.T) {
+	c, _, shutdown := newTestClient(t)
+	defer shutdown()
+
+	rev := putKeys(t, c, 10)
+	if rev != 10 {
+		t.Fatalf("commit: expected revision 10, have %d", rev)
+	}
+	if current, err := c.Rev(); err != nil || current != 10 {
+		t.Fatalf("rev: expected revision 10, have %d (%v)", current, err)
+	}
+
+	data, created, current, err := c.Get([]byte("k004"), 0, false)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if data != int64(4) || created != 5 || current != 10 {
+		t.Fatalf("get: expected (4, 5, 10), have (%v, %d, %d)", data, created, current)
+	}
+	if _, _, _, err = c.Get([]byte("missing"), 0, false); err != db.ErrKeyNotFound {
+		t.Fatalf("get: expected ErrKeyNotFound, have %v", err)
+	}
+
+	n, current, err := c.Range([]byte("k002"), []byte("k005"), 0, 0)
+	if err != nil {
+		t.Fatalf("range: %v", err)
+	}
+	if current != 10 {
+		t.Fatalf("range: expected revision 10, have %d", current)
+	}
+	count := 0
+	for ev := range n.Recv() {
+		if ev.Err() != nil {
+			if ev.Err() != db.NotifierCanceled {
+				t.Fatalf("range: unexpected error %v", ev.Err())
+			}
+			break
+		}
+		if want := fmt.Sprintf("k%.3d", count+2); string(ev.Key) != want {
+			t.Fatalf("range: expected key %q, have %q", want, ev.Key)
+		}
+		count++
+	}
+	if count != 3 {
+		t.Fatalf("range: expected 3 keys, have %d", count)
+	}
+
+	if _, _, err = c.Range([]byte("b"), []byte("a"), 0, 0); err != db.ErrInvertedRange {
+		t.Fatalf("range: expected ErrInvertedRange, have %v", err)
+	}
+
+	n, _, err = c.Range([]byte("missing"), nil, 0, 0)
+	if err != nil {
+		t.Fatalf("range: %v", err)
+	}
+	if ev := <-n.Recv(); ev.Err() != db.ErrKeyNotFound {
+		t.Fatalf("range: expected ErrKeyNotFound, have %v", ev.Err())
+	}
+}
+
+func TestClientTxn(t *testing.T) {
+	c, d, shutdown := newTestClient(t)
+	defer shutdown()
+
+	putKeys(t, c, 1)
+
+	tx, err := c.Txn()
+	if err != nil {
+		t.Fatalf("txn: %v", err)
+	}
+	rev, err := tx.Update([]byte("k000"), func(data interface{}) interface{} {
+		return data.(int64) + 41
+	}, false)
+	if err != nil || rev != 2 {
+		t.Fatalf("update: expected revision 2, have %d (%v)", rev, err)
+	}
+	if _, err = tx.Put([]byte("k000"), "string", false); err != db.ErrIncompatibleValue {
+		t.Fatalf("put: expected ErrIncompatibleValue, have %v", err)
+	}
+	if data, _, err := tx.Get([]byte("k000")); err != nil || data != int64(41) {
+		t.Fatalf("txn get: expected 41, have %v (%v)", data, err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if _, err = tx.Put([]byte("k000"), int64(1), false); err != ErrTxnClosed {
+		t.Fatalf("put: expected ErrTxnClosed, have %v", err)
+	}
+
+	if data, _, _, _ := d.Get([]byte("k000"), 0, false); data != int64(0) {
+		t.Fatalf("rollback: expected 0, have %v", data)
+	}
+
+	// Dropping a session must release the database writer.
+	tx, err = c.Txn()
+	if err != nil {
+		t.Fatalf("txn: %v", err)
+	}
+	tx.cancel()
+	done := make(chan struct{})
+	go func() {
+		d.Txn().Rollback()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("txn: writer not released")
+	}
+}
+
+func TestClientWatch(t *testing.T) {
+	c, _, shutdown := newTestClient(t)
+	defer shutdown()
+
+	putKeys(t, c, 2)
+	if _, _, err := c.Watch([]byte("missing")); err != db.ErrKeyNotFound {
+		t.Fatalf("watch: expected ErrKeyNotFound, have %v", err)
+	}
+
+	n0, current, err := c.Watch([]byte("k000"))
+	if err != nil || current != 2 {
+		t.Fatalf("watch: expected revision 2, have %d (%v)", current, err)
+	}
+	n1, _, err := c.Watch([]byte("k001"))
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+
+	tx, err := c.Txn()
+	if err != nil {
+		t.Fatalf("txn: %v", err)
+	}
+	tx.Put([]byte("k000"), int64(10), false)
+	tx.Delete([]byte("k001"))
+	if _, err = tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	ev := <-n0.Recv()
+	if ev.Err() != nil || ev.Data != int64(10) || ev.Created != 3 {
+		t.Fatalf("watch: unexpected event %+v", ev)
+	}
+	ev = <-n1.Recv()
+	if ev.Err() != nil || ev.Current != 4 {
+		t.Fatalf("watch: unexpected event %+v", ev)
+	}
+	if ev = <-n1.Recv(); ev.Err() != db.PairDeleted {
+		t.Fatalf("watch: expected PairDeleted, have %v", ev.Err())
+	}
+
+	n0.Cancel()
+	if ev = <-n0.Recv(); ev.Err() != db.NotifierCanceled {
+		t.Fatalf("cancel: expected NotifierCanceled, have %v", ev.Err())
+	}
+	if _, ok := <-n0.Recv(); ok {
+		t.Fatalf("cancel: expected closed channel")
+	}
+}
+
+func TestNotifierOverflow(t *testing.T) {
+	keys := func(n *Notifier) (keys string, err error) {
+		for ev := range n.Recv() {
+			if err = ev.Err(); err != nil {
+				break
+			}
+			keys += string(ev.Key)
+		}
+		return keys, err
+	}
+
+	for i, test := range []struct {
+		policy   db.OverflowPolicy
+		keys     string
+		err      error
+		canceled bool
+	}{
+		{db.DropOldest, "bb", db.NotifierCanceled, false},
+		{db.Coalesce, "ab", db.NotifierCanceled, false},
+		{db.CancelSlow, "ab", db.ErrSlowConsumer, true},
+	} {
+		canceled := false
+		n := newNotifier(func() { canceled = true }, NotifierOptions{Capacity: 2, Policy: test.policy})
+		for _, key := range []string{"a", "b", "b"} {
+			n.push(Event{Key: []byte(key)})
+		}
+		n.close(db.NotifierCanceled)
+
+		keys, err := keys(n)
+		if keys != test.keys || err != test.err || canceled != test.canceled {
+			t.Fatalf("overflow #%d: expected %q %v %v, have %q %v %v",
+				i, test.keys, test.err, test.canceled, keys, err, canceled)
+		}
+	}
+
+	n := newNotifier(func() {}, NotifierOptions{Capacity: 1, Policy: db.Block})
+	n.push(Event{Key: []byte("a")})
+	pushed := make(chan bool)
+	go func() { pushed <- n.push(Event{Key: []byte("b")}) }()
+	select {
+	case <-pushed:
+		t.Fatalf("block: push did not wait for the consumer")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if ev := <-n.Recv(); string(ev.Key) != "a" || !<-pushed {
+		t.Fatalf("block: unexpected event %+v", ev)
+	}
+	n.Cancel()
+	if keys, err := keys(n); keys != "b" || err != db.NotifierCanceled {
+		t.Fatalf("block: expected %q %v, have %q %v", "b", db.NotifierCanceled, keys, err)
+	}
+}
+
+func TestClientWatchSlowConsumer(t *testing.T) {
+	c, _, shutdown := newTestClient(t)
+	defer shutdown()
+
+	putKeys(t, c, 1)
+	n, _, err := c.WatchWith([]byte("k000"), NotifierOptions{Capacity: 1, Policy: db.CancelSlow})
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		putKeys(t, c, 1)
+	}
+
+	// Wait until the queue has overflowed before receiving.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n.mu.Lock()
+		closed := n.closed
+		n.mu.Unlock()
+		if closed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("watch: queue did not overflow")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for ev := range n.Recv() {
+		if err = ev.Err(); err != nil {
+			break
+		}
+	}
+	if err != db.ErrSlowConsumer {
+		t.Fatalf("watch: expected %v, have %v", db.ErrSlowConsumer, err)
+	}
+}
+
+func TestClientValueTypes(t *testing.T) {
+	c, d, shutdown := newTestClient(t)
+	defer shutdown()
+
+	values := []interface{}{
+		int(-1), int8(-8), int16(-16), int32(-32), int64(-64),
+		uint(1), uint8(8), uint16(16), uint32(32), uint64(64),
+		float32(0.5), float64(0.25),
+	}
+	tx := d.Txn()
+	for i, v := range values {
+		tx.Put([]byte(fmt.Sprintf("k%.3d", i)), v, false)
+	}
+	tx.Commit()
+
+	rtx, err := c.Txn()
+	if err != nil {
+		t.Fatalf("txn: %v", err)
+	}
+	for i, v := range values {
+		key := []byte(fmt.Sprintf("k%.3d", i))
+		if data, _, err := rtx.Get(key); err != nil || data != v {
+			t.Fatalf("get %T: expected %v, have %T %v (%v)", v, v, data, data, err)
+		}
+		if _, err = rtx.Put(key, v, false); err != nil {
+			t.Fatalf("put %T: %v", v, err)
+		}
+	}
+	if _, err = rtx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	for i, v := range values {
+		data, _, _, err := c.Get([]byte(fmt.Sprintf("k%.3d", i)), 0, false)
+		if err != nil || data != v {
+			t.Fatalf("get %T: expected %v, have %T %v (%v)", v, v, data, data, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/azmodb/db"
+)
+
+// defaultNotifierCapacity bounds the queue of a notifier if no capacity
+// is given.
+const defaultNotifierCapacity = 1024
+
+// Event represents a database key or range search query result. This
+// structure must be kept immutable.
+type Event struct {
+	Data    interface{}
+	Created int64
+	Current int64
+	Key     []byte
+	err     error
+}
+
+// Err returns an error if any.
+func (e Event) Err() error { return e.err }
+
+// NotifierOptions configures the local queue of a notifier.
+type NotifierOptions struct {
+	// Capacity is the maximum number of queued events. If Capacity <= 0
+	// a default capacity is used.
+	Capacity int
+
+	// Policy determines what happens to new events when the queue is
+	// full, see db.OverflowPolicy. The zero value is db.DropOldest.
+	// All watchers of a client share a single stream, a watcher using
+	// db.Block which is not received from stalls all other watchers.
+	Policy db.OverflowPolicy
+}
+
+// Notifier represents a remote database event notifier. Events are
+// queued until they are received, the queue is bounded by the
+// notifier options.
+type Notifier struct {
+	cancel func()
+	out    chan Event
+	opts   NotifierOptions
+
+	once    sync.Once
+	mu      sync.Mutex
+	cond    *sync.Cond // signals queued events and free capacity
+	pending []Event
+	closed  bool
+}
+
+func newNotifier(cancel func(), opts NotifierOptions) *Notifier {
+	if opts.Capacity <= 0 {
+		opts.Capacity = defaultNotifierCapacity
+	}
+	n := &Notifier{cancel: cancel, out: make(chan Event), opts: opts}
+	n.cond = sync.NewCond(&n.mu)
+	return n
+}
+
+// run delivers queued events in order. It is started by the first call
+// to Recv. The receiving channel is closed
+// after the terminal error event has been delivered.
+func (n *Notifier) run() {
+	defer close(n.out)
+	for {
+		n.mu.Lock()
+		for len(n.pending) == 0 {
+			n.cond.Wait()
+		}
+		ev := n.pending[0]
+		n.pending[0] = Event{}
+		n.pending = n.pending[1:]
+		n.cond.Broadcast()
+		n.mu.Unlock()
+
+		n.out <- ev
+		if ev.err != nil {
+			return
+		}
+	}
+}
+
+// push queues an event. After an error event has been queued, all
+// further events are discarded. If the queue is full, the overflow
+// policy of the notifier applies. The terminal error event is always
+// queued.
+func (n *Notifier) push(ev Event) bool {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return false
+	}
+	for ev.err == nil && len(n.pending) >= n.opts.Capacity {
+		switch n.opts.Policy {
+		case db.Coalesce:
+			if n.coalesce(ev.Key) {
+				continue
+			}
+			fallthrough
+		case db.DropOldest:
+			n.pending[0] = Event{}
+			n.pending = n.pending[1:]
+		case db.CancelSlow:
+			n.closed = true
+			n.pending = append(n.pending, Event{err: db.ErrSlowConsumer})
+			n.cond.Broadcast()
+			n.mu.Unlock()
+			n.cancel() // stop the remote notifier
+			return false
+		default:
+			n.cond.Wait()
+			if n.closed {
+				n.mu.Unlock()
+				return false
+			}
+		}
+	}
+	n.closed = ev.err != nil
+	n.pending = append(n.pending, ev)
+	n.cond.Broadcast()
+	n.mu.Unlock()
+	return true
+}
+
+// coalesce removes all queued events for key and reports whether any
+// event has been removed.
+func (n *Notifier) coalesce(key []byte) bool {
+	pending := n.pending[:0]
+	for _, ev := range n.pending {
+		if !bytes.Equal(ev.Key, key) {
+			pending = append(pending, ev)
+		}
+	}
+	for i := len(pending); i < len(n.pending); i++ {
+		n.pending[i] = Event{}
+	}
+	removed := len(pending) < len(n.pending)
+	n.pending = pending
+	return removed
+}
+
+func (n *Notifier) close(err error) { n.push(Event{err: err}) }
+
+// Cancel cancels the notifier. The receiving channel delivers a
+// NotifierCanceled error event and is closed.
+func (n *Notifier) Cancel() {
+	n.close(db.NotifierCanceled)
+	n.cancel()
+}
+
+// Recv returns the receiving channel part.
+func (n *Notifier) Recv() <-chan Event {
+	n.once.Do(func() { go n.run() })
+	return n.out
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"errors"
+
+	"github.com/azmodb/db"
+	"github.com/azmodb/db/rpcpb"
+)
+
+// ErrTxnClosed is returned when a transaction has already been
+// committed or rolled back.
+var ErrTxnClosed = errors.New("client: transaction closed")
+
+// Txn represents a remote batch transaction. The server holds the
+// database writer for the lifetime of the transaction, a transaction
+// must be committed or rolled back.
+type Txn struct {
+	stream rpcpb.DB_TxnClient
+	cancel context.CancelFunc
+	err    error // first stream error
+}
+
+// Txn starts a new batch transaction session. Only one batch
+// transaction can be used at a time, Txn blocks until the server
+// acquired the database writer.
+func (c *Client) Txn() (*Txn, error) {
+	ctx, cancel := context.WithCancel(c.ctx)
+	stream, err := c.rpc.Txn(ctx)
+	if err != nil {
+		cancel()
+		return nil, rpcpb.FromStatus(err)
+	}
+	if _, err = stream.Recv(); err != nil {
+		cancel()
+		return nil, rpcpb.FromStatus(err)
+	}
+	return &Txn{stream: stream, cancel: cancel}, nil
+}
+
+func (tx *Txn) call(req *rpcpb.TxnRequest) (*rpcpb.TxnResponse, error) {
+	if tx.err != nil {
+		return nil, tx.err
+	}
+	if err := tx.stream.Send(req); err != nil {
+		tx.close(err)
+		return nil, tx.err
+	}
+	resp, err := tx.stream.Recv()
+	if err != nil {
+		tx.close(err)
+		return nil, tx.err
+	}
+	return resp, nil
+}
+
+func (tx *Txn) close(err error) {
+	if tx.err == nil {
+		tx.err = rpcpb.FromStatus(err)
+	}
+	tx.cancel()
+}
+
+// Update updates the value for a key. The current value is read from
+// the transaction, passed to up and the result is written back. See
+// db.Txn.Update for details.
+func (tx *Txn) Update(key []byte, up db.Updater, tombstone bool) (int64, error) {
+	data, _, err := tx.Get(key)
+	if err != nil && err != db.ErrKeyNotFound {
+		return 0, err
+	}
+	return tx.Put(key, up(data), tombstone)
+}
+
+// Put sets the value for a key. If the key exists and tombstone is true
+// then its previous versions will be overwritten.
+//
+// It the key exists and the value data type differ, it returns an error.
+func (tx *Txn) Put(key []byte, data interface{}, tombstone bool) (int64, error) {
+	value, err := rpcpb.NewValue(data)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := tx.call(&rpcpb.TxnRequest{
+		Request: &rpcpb.TxnRequest_Put{
+			Put: &rpcpb.TxnPut{Key: key, Value: value, Tombstone: tombstone},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Rev, rpcpb.Error(resp.Error)
+}
+
+// Delete removes a key/value pair and returns the current revision of
+// the transaction.
+func (tx *Txn) Delete(key []byte) (int64, error) {
+	resp, err := tx.call(&rpcpb.TxnRequest{
+		Request: &rpcpb.TxnRequest_Delete{
+			Delete: &rpcpb.TxnDelete{Key: key},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Rev, nil
+}
+
+// Get returns the current value for a key within the transaction,
+// including uncommitted updates, and the revision of the value.
+func (tx *Txn) Get(key []byte) (interface{}, int64, error) {
+	resp, err := tx.call(&rpcpb.TxnRequest{
+		Request: &rpcpb.TxnRequest_Get{
+			Get: &rpcpb.TxnGet{Key: key},
+		},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if err = rpcpb.Error(resp.Error); err != nil {
+		return nil, 0, err
+	}
+	return resp.Value.Data(), resp.Created, nil
+}
+
+// Commit closes the transaction and writes all changes into the
+// database. It returns the revision of the committed transaction.
+func (tx *Txn) Commit() (int64, error) {
+	resp, err := tx.call(&rpcpb.TxnRequest{
+		Request: &rpcpb.TxnRequest_Commit{Commit: true},
+	})
+	if err != nil {
+		return 0, err
+	}
+	tx.close(ErrTxnClosed)
+	return resp.Rev, rpcpb.Error(resp.Error)
+}
+
+// Rollback closes the transaction and ignores all previous updates.
+func (tx *Txn) Rollback() error {
+	if tx.err == ErrTxnClosed {
+		return nil
+	}
+	_, err := tx.call(&rpcpb.TxnRequest{
+		Request: &rpcpb.TxnRequest_Rollback{Rollback: true},
+	})
+	tx.close(ErrTxnClosed)
+	return err
+}
@@ -0,0 +1,72 @@
+// Command azmodb-server serves an AzmoDB database over HTTP with JSON
+// bodies and, optionally, over gRPC and the Redis protocol.
+//
+// Usage:
+//
+//	azmodb-server [-addr :7070] [-grpc :7071] [-resp :6379] [-db path] [-snapshot interval]
+//
+// If a database path is given, the database is loaded from the path
+// and periodically written back to it.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/azmodb/db"
+	"github.com/azmodb/db/resp"
+	"github.com/azmodb/db/rpc"
+	"github.com/azmodb/db/server"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	addr := flag.String("addr", ":7070", "HTTP listen address")
+	grpcAddr := flag.String("grpc", "", "gRPC listen address")
+	respAddr := flag.String("resp", "", "Redis protocol listen address")
+	path := flag.String("db", "", "database snapshot file")
+	interval := flag.Duration("snapshot", time.Minute, "snapshot interval")
+	flag.Parse()
+
+	d := db.New()
+	if *path != "" {
+		var err error
+		if d, err = db.Load(*path, 10*time.Second); err != nil {
+			log.Fatalf("azmodb-server: loading %q: %v", *path, err)
+		}
+		go snapshot(d, *interval)
+	}
+
+	if *grpcAddr != "" {
+		ln, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("azmodb-server: %v", err)
+		}
+		s := grpc.NewServer()
+		rpc.Register(s, d)
+		go func() {
+			log.Printf("azmodb-server: serving gRPC on %s", *grpcAddr)
+			log.Fatal(s.Serve(ln))
+		}()
+	}
+	if *respAddr != "" {
+		go func() {
+			log.Printf("azmodb-server: serving Redis protocol on %s", *respAddr)
+			log.Fatal(resp.New(d).ListenAndServe(*respAddr))
+		}()
+	}
+
+	log.Printf("azmodb-server: listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, server.New(d)))
+}
+
+func snapshot(d *db.DB, interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, _, err := d.Snapshot(); err != nil {
+			log.Printf("azmodb-server: snapshot: %v", err)
+		}
+	}
+}
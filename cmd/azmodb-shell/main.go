@@ -0,0 +1,101 @@
+// Command azmodb-shell is an interactive shell for an AzmoDB database,
+// either loaded from a snapshot file or served by a running
+// azmodb-server over gRPC.
+//
+// Usage:
+//
+//	azmodb-shell -db path
+//	azmodb-shell -addr host:port
+//
+// Type help for a list of commands. Keys and command names are
+// completed with the tab key. If standard input is not a terminal,
+// commands are read line by line without a prompt.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/azmodb/db"
+	"github.com/azmodb/db/client"
+	"golang.org/x/term"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	path := flag.String("db", "", "database snapshot file")
+	addr := flag.String("addr", "", "gRPC address of a running server")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("azmodb-shell: ")
+
+	var sh *shell
+	switch {
+	case *path != "" && *addr == "":
+		d, err := db.Load(*path, 5*time.Second)
+		if err != nil {
+			log.Fatalf("loading %q: %v", *path, err)
+		}
+		sh = newShell(local{d}, os.Stdout)
+		sh.snapshotFn = func() (int64, error) {
+			rev, _, err := d.Snapshot()
+			return rev, err
+		}
+	case *addr != "" && *path == "":
+		c, err := client.Dial(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			log.Fatalf("connecting to %s: %v", *addr, err)
+		}
+		defer c.Close()
+		sh = newShell(remote{c}, os.Stdout)
+	default:
+		fmt.Fprintln(os.Stderr, "usage: azmodb-shell -db path | -addr host:port")
+		os.Exit(2)
+	}
+	defer sh.close()
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if err := sh.exec(scanner.Text()); err == io.EOF {
+				return
+			} else if err != nil {
+				sh.printf("error: %v\n", err)
+			}
+		}
+		return
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer term.Restore(fd, state)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, sh.prompt())
+	t.AutoCompleteCallback = sh.complete
+	sh.out = t
+
+	for {
+		t.SetPrompt(sh.prompt())
+		line, err := t.ReadLine()
+		if err != nil {
+			return
+		}
+		if err = sh.exec(line); err == io.EOF {
+			return
+		} else if err != nil {
+			sh.printf("error: %v\n", err)
+		}
+	}
+}
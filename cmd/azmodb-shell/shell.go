@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/azmodb/db"
+)
+
+const (
+	maxCompletions = 64
+	defaultLimit   = 100
+)
+
+var errUsage = errors.New("usage")
+
+type shellCommand struct {
+	args string // argument synopsis
+	help string
+	key  bool // first argument is a key
+	fn   func(sh *shell, args []arg) error
+}
+
+var commands map[string]shellCommand
+
+func init() {
+	commands = map[string]shellCommand{
+		"get":      {"KEY", "print the value of a key", true, (*shell).get},
+		"range":    {"[FROM [TO]] [limit N]", "print the keys from <= key < to", true, (*shell).scan},
+		"put":      {"KEY VALUE", "set the value of a key", true, (*shell).put},
+		"del":      {"KEY", "delete a key", true, (*shell).del},
+		"watch":    {"KEY", "print changes of a key", true, (*shell).watch},
+		"unwatch":  {"KEY", "stop watching a key", true, (*shell).unwatch},
+		"rev":      {"", "print the current revision", false, (*shell).rev},
+		"txn":      {"begin|commit|rollback", "manage a transaction", false, (*shell).txn},
+		"at":       {"[REV]", "read at a revision, without REV read the current revision", false, (*shell).at},
+		"snapshot": {"", "write the database to its snapshot file", false, (*shell).snapshot},
+		"help":     {"", "print this help", false, (*shell).help},
+	}
+}
+
+// shell executes shell command lines against a store.
+type shell struct {
+	store      store
+	snapshotFn func() (int64, error) // nil if the store has no file
+
+	mu      sync.Mutex // serializes output, protects watches
+	out     io.Writer
+	watches map[string]*watcher
+
+	tx      txn
+	readRev int64 // read revision set by at, 0 reads the current revision
+}
+
+type watcher struct {
+	cancel func()
+}
+
+func newShell(s store, out io.Writer) *shell {
+	return &shell{store: s, out: out, watches: make(map[string]*watcher)}
+}
+
+// arg represents a command line argument. Quoted is set if the
+// argument was enclosed in double quotes.
+type arg struct {
+	s      string
+	quoted bool
+}
+
+// splitArgs splits a command line into arguments. Arguments are
+// separated by white space, double quoted arguments may contain white
+// space and Go escape sequences.
+func splitArgs(line string) ([]arg, error) {
+	var args []arg
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return args, nil
+		}
+		if line[0] != '"' {
+			i := strings.IndexAny(line, " \t")
+			if i < 0 {
+				i = len(line)
+			}
+			args = append(args, arg{s: line[:i]})
+			line = line[i:]
+			continue
+		}
+
+		prefix, err := strconv.QuotedPrefix(line)
+		if err != nil {
+			return nil, errors.New("unterminated quoted argument")
+		}
+		s, _ := strconv.Unquote(prefix)
+		args = append(args, arg{s: s, quoted: true})
+		line = line[len(prefix):]
+	}
+}
+
+// parseValue returns the value of a put argument. Quoted arguments are
+// strings, unquoted arguments are parsed as int64, float64 or bool and
+// stored as []byte otherwise.
+func parseValue(a arg) interface{} {
+	if a.quoted {
+		return a.s
+	}
+	if n, err := strconv.ParseInt(a.s, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(a.s, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(a.s); err == nil {
+		return b
+	}
+	return []byte(a.s)
+}
+
+func formatValue(data interface{}) string {
+	switch v := data.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return strconv.Quote(v)
+	}
+	return fmt.Sprint(data)
+}
+
+func (sh *shell) printf(format string, args ...interface{}) {
+	sh.mu.Lock()
+	fmt.Fprintf(sh.out, format, args...)
+	sh.mu.Unlock()
+}
+
+// prompt returns the prompt reflecting the shell state.
+func (sh *shell) prompt() string {
+	p := "azmodb"
+	if sh.readRev > 0 {
+		p += "@" + strconv.FormatInt(sh.readRev, 10)
+	}
+	if sh.tx != nil {
+		p += "(txn)"
+	}
+	return p + "> "
+}
+
+// exec executes a command line. It returns io.EOF if the shell should
+// exit.
+func (sh *shell) exec(line string) error {
+	args, err := splitArgs(line)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	name := strings.ToLower(args[0].s)
+	if name == "exit" || name == "quit" {
+		return io.EOF
+	}
+	cmd, found := commands[name]
+	if !found {
+		return fmt.Errorf("unknown command %q, try help", args[0].s)
+	}
+	if err = cmd.fn(sh, args[1:]); err == errUsage {
+		err = fmt.Errorf("usage: %s %s", name, cmd.args)
+	}
+	return err
+}
+
+// close rolls back a running transaction and stops all watchers.
+func (sh *shell) close() {
+	if sh.tx != nil {
+		sh.tx.rollback()
+		sh.tx = nil
+	}
+	sh.mu.Lock()
+	watches := sh.watches
+	sh.watches = make(map[string]*watcher)
+	sh.mu.Unlock()
+	for _, w := range watches {
+		w.cancel()
+	}
+}
+
+func (sh *shell) get(args []arg) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	key := []byte(args[0].s)
+
+	var (
+		data    interface{}
+		created int64
+		err     error
+	)
+	if sh.tx != nil && sh.readRev == 0 {
+		data, created, err = sh.tx.get(key)
+	} else {
+		data, created, err = sh.store.get(key, sh.readRev)
+	}
+	if err != nil {
+		return err
+	}
+	sh.printf("%s\t%d\n", formatValue(data), created)
+	return nil
+}
+
+func (sh *shell) scan(args []arg) error {
+	limit := defaultLimit
+	if n := len(args); n >= 2 && strings.ToLower(args[n-2].s) == "limit" {
+		var err error
+		if limit, err = strconv.Atoi(args[n-1].s); err != nil || limit < 1 {
+			return errUsage
+		}
+		args = args[:n-2]
+	}
+	if len(args) > 2 {
+		return errUsage
+	}
+
+	var from, to []byte
+	if len(args) > 0 {
+		from = []byte(args[0].s)
+	}
+	if len(args) > 1 {
+		to = []byte(args[1].s)
+	}
+
+	count := 0
+	lo := from
+	if to == nil { // open ended, filter all keys
+		lo = nil
+	}
+	return sh.store.scan(lo, to, sh.readRev, 0, func(ev event) bool {
+		if bytes.Compare(ev.key, from) < 0 {
+			return true
+		}
+		if to != nil && bytes.Compare(ev.key, to) >= 0 {
+			return false
+		}
+		sh.printf("%s\t%s\t%d\n", ev.key, formatValue(ev.data), ev.created)
+		count++
+		return count < limit
+	})
+}
+
+// update runs fn within the current transaction or, if there is no
+// transaction, within a new transaction which is committed.
+func (sh *shell) update(fn func(tx txn) (int64, error)) error {
+	if sh.readRev > 0 {
+		return errors.New("cannot update at a past revision, reset with at")
+	}
+	if sh.tx != nil {
+		rev, err := fn(sh.tx)
+		if err == nil {
+			sh.printf("%d\n", rev)
+		}
+		return err
+	}
+
+	tx, err := sh.store.begin()
+	if err != nil {
+		return err
+	}
+	if _, err = fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+	rev, err := tx.commit()
+	if err == nil {
+		sh.printf("%d\n", rev)
+	}
+	return err
+}
+
+func (sh *shell) put(args []arg) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	key, data := []byte(args[0].s), parseValue(args[1])
+	return sh.update(func(tx txn) (int64, error) { return tx.put(key, data) })
+}
+
+func (sh *shell) del(args []arg) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	key := []byte(args[0].s)
+	return sh.update(func(tx txn) (int64, error) { return tx.del(key) })
+}
+
+func (sh *shell) watch(args []arg) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	key := args[0].s
+	sh.mu.Lock()
+	_, found := sh.watches[key]
+	sh.mu.Unlock()
+	if found {
+		return fmt.Errorf("already watching %s", key)
+	}
+
+	events, cancel, err := sh.store.watch([]byte(key))
+	if err != nil {
+		return err
+	}
+	w := &watcher{cancel: cancel}
+	sh.mu.Lock()
+	sh.watches[key] = w
+	sh.mu.Unlock()
+
+	go func() {
+		for ev := range events {
+			switch ev.err {
+			case nil:
+				sh.printf("watch %s: %s\t%d\n", key, formatValue(ev.data), ev.created)
+			case db.PairDeleted:
+				sh.printf("watch %s: deleted\n", key)
+			case db.NotifierCanceled:
+			default:
+				sh.printf("watch %s: %v\n", key, ev.err)
+			}
+		}
+
+		sh.mu.Lock()
+		if sh.watches[key] == w {
+			delete(sh.watches, key)
+		}
+		sh.mu.Unlock()
+	}()
+	return nil
+}
+
+func (sh *shell) unwatch(args []arg) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	sh.mu.Lock()
+	w, found := sh.watches[args[0].s]
+	delete(sh.watches, args[0].s)
+	sh.mu.Unlock()
+	if !found {
+		return fmt.Errorf("not watching %s", args[0].s)
+	}
+	w.cancel()
+	return nil
+}
+
+func (sh *shell) rev(args []arg) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	rev, err := sh.store.rev()
+	if err == nil {
+		sh.printf("%d\n", rev)
+	}
+	return err
+}
+
+func (sh *shell) txn(args []arg) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	switch strings.ToLower(args[0].s) {
+	case "begin":
+		if sh.tx != nil {
+			return errors.New("transaction already running")
+		}
+		tx, err := sh.store.begin()
+		if err != nil {
+			return err
+		}
+		sh.tx = tx
+		return nil
+	case "commit":
+		if sh.tx == nil {
+			return errors.New("no transaction running")
+		}
+		rev, err := sh.tx.commit()
+		sh.tx = nil
+		if err == nil {
+			sh.printf("%d\n", rev)
+		}
+		return err
+	case "rollback":
+		if sh.tx == nil {
+			return errors.New("no transaction running")
+		}
+		err := sh.tx.rollback()
+		sh.tx = nil
+		return err
+	}
+	return errUsage
+}
+
+func (sh *shell) at(args []arg) error {
+	switch len(args) {
+	case 0:
+		sh.readRev = 0
+		return nil
+	case 1:
+		rev, err := strconv.ParseInt(args[0].s, 10, 64)
+		if err != nil || rev < 0 {
+			return errUsage
+		}
+		sh.readRev = rev
+		return nil
+	}
+	return errUsage
+}
+
+func (sh *shell) snapshot(args []arg) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	if sh.snapshotFn == nil {
+		return errors.New("database has no snapshot file")
+	}
+	rev, err := sh.snapshotFn()
+	if err == nil {
+		sh.printf("%d\n", rev)
+	}
+	return err
+}
+
+func (sh *shell) help(args []arg) error {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := commands[name]
+		sh.printf("  %-28s %s\n", strings.TrimSpace(name+" "+cmd.args), cmd.help)
+	}
+	sh.printf("  %-28s %s\n", "exit", "leave the shell")
+	return nil
+}
+
+// complete implements tab completion of command names and keys. It
+// returns the completed line or, if the completion is ambiguous, prints
+// the candidates.
+func (sh *shell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' || pos != len(line) {
+		return "", 0, false
+	}
+
+	i := strings.LastIndexAny(line, " \t")
+	prefix := line[i+1:]
+	var candidates []string
+	switch fields := strings.Fields(line[:i+1]); {
+	case len(fields) == 0:
+		for name := range commands {
+			if strings.HasPrefix(name, prefix) {
+				candidates = append(candidates, name+" ")
+			}
+		}
+	case commands[strings.ToLower(fields[0])].key:
+		candidates = sh.keys(prefix)
+	default:
+		return "", 0, false
+	}
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+
+	sort.Strings(candidates)
+	common := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if common == prefix && len(candidates) > 1 {
+		sh.printf("%s\n", strings.Join(candidates, "  "))
+		return "", 0, false
+	}
+	line = line[:i+1] + common
+	return line, len(line), true
+}
+
+// keys returns up to maxCompletions keys starting with prefix.
+func (sh *shell) keys(prefix string) []string {
+	from := []byte(prefix)
+	var to []byte
+	for i := len(from) - 1; i >= 0; i-- { // smallest key following all keys with prefix
+		if from[i] < 0xff {
+			to = append(append(to, from[:i]...), from[i]+1)
+			break
+		}
+	}
+	if len(from) == 0 || to == nil {
+		from = nil
+	}
+
+	var keys []string
+	sh.store.scan(from, to, sh.readRev, 0, func(ev event) bool {
+		if bytes.HasPrefix(ev.key, []byte(prefix)) {
+			keys = append(keys, string(ev.key))
+		}
+		return len(keys) < maxCompletions
+	})
+	return keys
+}
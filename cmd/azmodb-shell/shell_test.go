@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azmodb/db"
+)
+
+func TestShell(t *testing.T) {
+	out := &bytes.Buffer{}
+	sh := newShell(local{db.New()}, out)
+	defer sh.close()
+
+	for i, test := range []struct {
+		line string
+		want string
+		err  bool
+	}{
+		{line: "put a 1", want: "1\n"},
+		{line: `put b "two words"`, want: "2\n"},
+		{line: "put c bytes", want: "3\n"},
+		{line: "get a", want: "1\t1\n"},
+		{line: "get b", want: "\"two words\"\t2\n"},
+		{line: "put a 2", want: "4\n"},
+		{line: "put a x", err: true},
+		{line: "range", want: "a\t2\t4\nb\t\"two words\"\t2\nc\tbytes\t3\n"},
+		{line: "range b", want: "b\t\"two words\"\t2\nc\tbytes\t3\n"},
+		{line: "range a c", want: "a\t2\t4\nb\t\"two words\"\t2\n"},
+		{line: "range limit 1", want: "a\t2\t4\n"},
+		{line: "rev", want: "4\n"},
+		{line: "at 3"},
+		{line: "get a", want: "1\t1\n"},
+		{line: "put a 3", err: true},
+		{line: "at"},
+		{line: "txn begin"},
+		{line: "put d 1.5", want: "5\n"},
+		{line: "del c", want: "6\n"},
+		{line: "get d", want: "1.5\t5\n"},
+		{line: "txn rollback"},
+		{line: "get d", err: true},
+		{line: "txn begin"},
+		{line: "del c", want: "5\n"},
+		{line: "txn commit", want: "5\n"},
+		{line: "get c", err: true},
+		{line: "del c", err: true},
+		{line: "txn commit", err: true},
+		{line: "get", err: true},
+		{line: "foo", err: true},
+		{line: `get "a`, err: true},
+	} {
+		out.Reset()
+		err := sh.exec(test.line)
+		if (err != nil) != test.err {
+			t.Fatalf("shell #%d %q: unexpected error %v", i, test.line, err)
+		}
+		if out.String() != test.want {
+			t.Fatalf("shell #%d %q: expected %q, have %q", i, test.line, test.want, out.String())
+		}
+	}
+
+	if err := sh.exec("exit"); err != io.EOF {
+		t.Fatalf("exit: expected io.EOF, have %v", err)
+	}
+}
+
+type syncBuffer struct {
+	sh  *shell
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) { return b.buf.Write(p) }
+
+func (b *syncBuffer) String() string {
+	b.sh.mu.Lock()
+	defer b.sh.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestShellWatch(t *testing.T) {
+	out := &syncBuffer{}
+	sh := newShell(local{db.New()}, out)
+	out.sh = sh
+	defer sh.close()
+
+	sh.exec("put a 1")
+	if err := sh.exec("watch a"); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if err := sh.exec("watch a"); err == nil {
+		t.Fatalf("watch: expected already watching error")
+	}
+	sh.exec("put a 2")
+	sh.exec("del a")
+
+	want := "watch a: 2\t2\nwatch a: deleted\n"
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.HasSuffix(out.String(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("watch: expected suffix %q, have %q", want, out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShellComplete(t *testing.T) {
+	out := &bytes.Buffer{}
+	sh := newShell(local{db.New()}, out)
+	defer sh.close()
+	for _, line := range []string{"put key1 1", "put key2 2", "put other 3"} {
+		sh.exec(line)
+	}
+	out.Reset()
+
+	for i, test := range []struct {
+		line, want string
+		ok         bool
+	}{
+		{"ge", "get ", true},
+		{"get o", "get other", true},
+		{"get k", "get key", true},
+		{"get key", "", false}, // ambiguous, prints candidates
+		{"rev x", "", false},
+	} {
+		line, pos, ok := sh.complete(test.line, len(test.line), '\t')
+		if ok != test.ok || line != test.want || (ok && pos != len(line)) {
+			t.Fatalf("complete #%d %q: expected (%q, %v), have (%q, %v)", i, test.line, test.want, test.ok, line, ok)
+		}
+	}
+	if out.String() != "key1  key2\n" {
+		t.Fatalf("complete: expected candidates, have %q", out.String())
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"github.com/azmodb/db"
+	"github.com/azmodb/db/client"
+)
+
+// event represents a range or watch result.
+type event struct {
+	key     []byte
+	data    interface{}
+	created int64
+	err     error
+}
+
+// store is the database the shell operates on, either an embedded
+// database or a remote server.
+type store interface {
+	get(key []byte, rev int64) (interface{}, int64, error)
+	scan(from, to []byte, rev int64, limit int32, fn func(event) bool) error
+	watch(key []byte) (<-chan event, func(), error)
+	rev() (int64, error)
+	begin() (txn, error)
+}
+
+type txn interface {
+	get(key []byte) (interface{}, int64, error)
+	put(key []byte, data interface{}) (int64, error)
+	del(key []byte) (int64, error)
+	commit() (int64, error)
+	rollback() error
+}
+
+// local is a store using an embedded database.
+type local struct {
+	db *db.DB
+}
+
+func (s local) get(key []byte, rev int64) (interface{}, int64, error) {
+	data, created, _, err := s.db.Get(key, rev, false)
+	return data, created, err
+}
+
+func (s local) scan(from, to []byte, rev int64, limit int32, fn func(event) bool) error {
+	n, _, err := s.db.Range(from, to, rev, limit)
+	if err != nil {
+		return err
+	}
+	defer n.Cancel()
+
+	for ev := range n.Recv() {
+		if err = ev.Err(); err != nil {
+			if err == db.NotifierCanceled {
+				err = nil
+			}
+			return err
+		}
+		if !fn(event{key: ev.Key, data: ev.Data, created: ev.Created}) {
+			return nil
+		}
+		if from != nil && to == nil { // single key request
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s local) watch(key []byte) (<-chan event, func(), error) {
+	n, _, err := s.db.Watch(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch := make(chan event)
+	go func() {
+		defer close(ch)
+		for ev := range n.Recv() {
+			ch <- event{key: key, data: ev.Data, created: ev.Created, err: ev.Err()}
+			if ev.Err() != nil {
+				return
+			}
+		}
+	}()
+	return ch, n.Cancel, nil
+}
+
+func (s local) rev() (int64, error) { return s.db.Rev(), nil }
+
+func (s local) begin() (txn, error) {
+	tx := s.db.Txn()
+	return &localTxn{tx: tx, rev: s.db.Rev()}, nil
+}
+
+type localTxn struct {
+	tx  *db.Txn
+	rev int64 // revision of the last update
+}
+
+func (t *localTxn) get(key []byte) (interface{}, int64, error) { return t.tx.Get(key) }
+
+func (t *localTxn) put(key []byte, data interface{}) (rev int64, err error) {
+	if rev, err = t.tx.Put(key, data, false); err == nil {
+		t.rev = rev
+	}
+	return rev, err
+}
+
+func (t *localTxn) del(key []byte) (int64, error) {
+	if _, _, err := t.tx.Get(key); err != nil {
+		return 0, err
+	}
+	t.rev = t.tx.Delete(key)
+	return t.rev, nil
+}
+
+func (t *localTxn) commit() (int64, error) {
+	if err := t.tx.Commit(); err != nil {
+		return 0, err
+	}
+	return t.rev, nil
+}
+
+func (t *localTxn) rollback() error {
+	t.tx.Rollback()
+	return nil
+}
+
+// remote is a store using a database server.
+type remote struct {
+	c *client.Client
+}
+
+func (s remote) get(key []byte, rev int64) (interface{}, int64, error) {
+	data, created, _, err := s.c.Get(key, rev, false)
+	return data, created, err
+}
+
+func (s remote) scan(from, to []byte, rev int64, limit int32, fn func(event) bool) error {
+	n, _, err := s.c.Range(from, to, rev, limit)
+	if err != nil {
+		return err
+	}
+	defer n.Cancel()
+
+	for ev := range n.Recv() {
+		if err = ev.Err(); err != nil {
+			if err == db.NotifierCanceled {
+				err = nil
+			}
+			return err
+		}
+		if !fn(event{key: ev.Key, data: ev.Data, created: ev.Created}) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s remote) watch(key []byte) (<-chan event, func(), error) {
+	n, _, err := s.c.Watch(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch := make(chan event)
+	go func() {
+		defer close(ch)
+		for ev := range n.Recv() {
+			ch <- event{key: key, data: ev.Data, created: ev.Created, err: ev.Err()}
+			if ev.Err() != nil {
+				return
+			}
+		}
+	}()
+	return ch, n.Cancel, nil
+}
+
+func (s remote) rev() (int64, error) { return s.c.Rev() }
+
+func (s remote) begin() (txn, error) {
+	tx, err := s.c.Txn()
+	if err != nil {
+		return nil, err
+	}
+	return remoteTxn{tx}, nil
+}
+
+type remoteTxn struct {
+	tx *client.Txn
+}
+
+func (t remoteTxn) get(key []byte) (interface{}, int64, error) { return t.tx.Get(key) }
+
+func (t remoteTxn) put(key []byte, data interface{}) (int64, error) {
+	return t.tx.Put(key, data, false)
+}
+
+func (t remoteTxn) del(key []byte) (int64, error) {
+	if _, _, err := t.tx.Get(key); err != nil {
+		return 0, err
+	}
+	return t.tx.Delete(key)
+}
+
+func (t remoteTxn) commit() (int64, error) { return t.tx.Commit() }
+
+func (t remoteTxn) rollback() error { return t.tx.Rollback() }
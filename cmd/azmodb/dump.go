@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/azmodb/db/backend"
+)
+
+// record represents a single key/value revision of a dump. Keys which
+// are not valid UTF-8 are written base64 encoded to KeyBase64. Type is
+// the Go type of the value.
+type record struct {
+	Key       string          `json:"key,omitempty"`
+	KeyBase64 []byte          `json:"key_base64,omitempty"`
+	Rev       int64           `json:"rev"`
+	Type      string          `json:"type"`
+	Value     json.RawMessage `json:"value"`
+}
+
+func dump(b *backend.DB, snap int64, w io.Writer) error {
+	srev, err := snapshot(b, snap)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	err = forEach(b, srev, func(p pair) error {
+		rec := record{}
+		if utf8.Valid(p.key) {
+			rec.Key = string(p.key)
+		} else {
+			rec.KeyBase64 = p.key
+		}
+		for _, blk := range p.blocks {
+			value, err := json.Marshal(blk.Data)
+			if err != nil {
+				return fmt.Errorf("key %s: %v", keyString(p.key), err)
+			}
+			rec.Rev, rec.Type, rec.Value = blk.Rev, typeName(blk.Data), value
+			if err = enc.Encode(&rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func typeName(data interface{}) string {
+	if _, ok := data.([]byte); ok {
+		return "bytes"
+	}
+	return fmt.Sprintf("%T", data)
+}
+
+// decodeValue decodes a dumped value of the named type.
+func decodeValue(typ string, raw json.RawMessage) (interface{}, error) {
+	var (
+		v   interface{}
+		err error
+	)
+	switch typ {
+	case "bytes":
+		var x []byte
+		err, v = json.Unmarshal(raw, &x), x
+	case "string":
+		var x string
+		err, v = json.Unmarshal(raw, &x), x
+	case "bool":
+		var x bool
+		err, v = json.Unmarshal(raw, &x), x
+	case "int":
+		var x int
+		err, v = json.Unmarshal(raw, &x), x
+	case "int8":
+		var x int8
+		err, v = json.Unmarshal(raw, &x), x
+	case "int16":
+		var x int16
+		err, v = json.Unmarshal(raw, &x), x
+	case "int32":
+		var x int32
+		err, v = json.Unmarshal(raw, &x), x
+	case "int64":
+		var x int64
+		err, v = json.Unmarshal(raw, &x), x
+	case "uint":
+		var x uint
+		err, v = json.Unmarshal(raw, &x), x
+	case "uint8":
+		var x uint8
+		err, v = json.Unmarshal(raw, &x), x
+	case "uint16":
+		var x uint16
+		err, v = json.Unmarshal(raw, &x), x
+	case "uint32":
+		var x uint32
+		err, v = json.Unmarshal(raw, &x), x
+	case "uint64":
+		var x uint64
+		err, v = json.Unmarshal(raw, &x), x
+	case "float32":
+		var x float32
+		err, v = json.Unmarshal(raw, &x), x
+	case "float64":
+		var x float64
+		err, v = json.Unmarshal(raw, &x), x
+	default:
+		return nil, fmt.Errorf("unsupported value type %q", typ)
+	}
+	return v, err
+}
+
+// load reads records written by dump and writes them as a new snapshot
+// revision. The snapshot revision is the highest revision of all
+// records, or the revision following the last snapshot revision if
+// that is higher.
+func load(b *backend.DB, r io.Reader) (int64, error) {
+	pairs := map[string][]block{}
+	max := int64(0)
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for line := 1; ; line++ {
+		var rec record
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, fmt.Errorf("record %d: %v", line, err)
+		}
+		if rec.Rev <= 0 {
+			return 0, fmt.Errorf("record %d: invalid revision %d", line, rec.Rev)
+		}
+		data, err := decodeValue(rec.Type, rec.Value)
+		if err != nil {
+			return 0, fmt.Errorf("record %d: %v", line, err)
+		}
+
+		key := rec.Key
+		if rec.KeyBase64 != nil {
+			key = string(rec.KeyBase64)
+		}
+		pairs[key] = append(pairs[key], block{Data: data, Rev: rec.Rev})
+		if rec.Rev > max {
+			max = rec.Rev
+		}
+	}
+	if len(pairs) == 0 {
+		return 0, fmt.Errorf("no records found")
+	}
+
+	last, err := b.Last()
+	if err != nil {
+		return 0, err
+	}
+	if n := revNumber(last); n >= max {
+		max = n + 1
+	}
+
+	keys := make([]string, 0, len(pairs))
+	for key, blocks := range pairs {
+		sort.Slice(blocks, func(i, j int) bool { return blocks[i].Rev < blocks[j].Rev })
+		for i := 1; i < len(blocks); i++ {
+			if blocks[i].Rev == blocks[i-1].Rev {
+				return 0, fmt.Errorf("key %s: duplicate revision %d", keyString([]byte(key)), blocks[i].Rev)
+			}
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	batch, err := b.Batch(revision(max))
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		value, err := encodeBlocks(pairs[key])
+		if err == nil {
+			err = batch.Put([]byte(key), value)
+		}
+		if err != nil {
+			batch.Rollback()
+			return 0, err
+		}
+	}
+	return max, batch.Close()
+}
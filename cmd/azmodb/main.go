@@ -0,0 +1,455 @@
+// Command azmodb inspects and manipulates AzmoDB snapshot files written
+// by DB.Snapshot. All commands work offline, the file must not be in use
+// by a running database.
+//
+// Usage:
+//
+//	azmodb revs FILE
+//		lists the snapshot revisions and their number of keys
+//	azmodb get [-snapshot N] [-rev N] FILE KEY
+//		prints the value and revision of a key
+//	azmodb range [-snapshot N] [-rev N] [-from KEY] [-to KEY] [-limit N] FILE
+//		prints the keys from <= key < to
+//	azmodb dump [-snapshot N] FILE
+//		writes all key/value revisions as JSON lines to stdout
+//	azmodb load FILE
+//		reads JSON lines written by dump from stdin and writes them as
+//		a new snapshot revision
+//	azmodb verify FILE
+//		checks the integrity of all snapshot revisions
+//	azmodb diff FILE REV1 REV2
+//		prints keys added (+), removed (-) or changed (~) between two
+//		snapshot revisions
+//	azmodb compact [-keep N] FILE DST
+//		copies the last N snapshot revisions to a new file, dropping
+//		unreferenced values
+//
+// If -snapshot is not given, the last snapshot revision is used. If -rev
+// is given, values are read at the database revision within the
+// snapshot.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/azmodb/db/backend"
+)
+
+const lockTimeout = 5 * time.Second
+
+var errRevisionNotFound = errors.New("revision not found")
+
+// block mirrors the revision blocks encoded by the database.
+type block struct {
+	Data interface{}
+	Rev  int64
+}
+
+type pair struct {
+	key    []byte
+	blocks []block
+}
+
+// at returns the value of the pair at database revision rev. If rev
+// <= 0 it returns the last value.
+func (p pair) at(rev int64) (block, bool) {
+	if rev <= 0 {
+		return p.blocks[len(p.blocks)-1], true
+	}
+	i := sort.Search(len(p.blocks), func(i int) bool {
+		return p.blocks[i].Rev > rev
+	})
+	if i == 0 {
+		return block{}, false
+	}
+	return p.blocks[i-1], true
+}
+
+func decodeBlocks(value []byte) ([]block, error) {
+	blocks := []block{}
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&blocks); err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return nil, errors.New("empty revision history")
+	}
+	return blocks, nil
+}
+
+func encodeBlocks(blocks []block) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := gob.NewEncoder(buf).Encode(blocks)
+	return buf.Bytes(), err
+}
+
+func revision(n int64) backend.Revision {
+	var rev backend.Revision
+	binary.BigEndian.PutUint64(rev[:], uint64(n))
+	return rev
+}
+
+func revNumber(rev backend.Revision) int64 {
+	return int64(binary.BigEndian.Uint64(rev[:]))
+}
+
+// snapshot returns the snapshot revision n or, if n <= 0, the last
+// snapshot revision.
+func snapshot(b *backend.DB, n int64) (backend.Revision, error) {
+	revs, err := b.Revisions()
+	if err != nil {
+		return backend.Revision{}, err
+	}
+	if len(revs) == 0 {
+		return backend.Revision{}, errors.New("no snapshot found")
+	}
+	if n <= 0 {
+		return revs[len(revs)-1], nil
+	}
+	for _, rev := range revs {
+		if revNumber(rev) == n {
+			return rev, nil
+		}
+	}
+	return backend.Revision{}, errRevisionNotFound
+}
+
+// forEach calls fn for all key/value pairs of a snapshot revision in
+// key order.
+func forEach(b *backend.DB, rev backend.Revision, fn func(p pair) error) error {
+	return b.Range(rev, func(key, value []byte) error {
+		blocks, err := decodeBlocks(value)
+		if err != nil {
+			return fmt.Errorf("key %s: %v", keyString(key), err)
+		}
+		return fn(pair{key: key, blocks: blocks})
+	})
+}
+
+func keyString(key []byte) string {
+	if utf8.Valid(key) && strconv.IsPrint(firstRune(key)) {
+		return string(key)
+	}
+	return strconv.Quote(string(key))
+}
+
+func firstRune(key []byte) rune {
+	r, _ := utf8.DecodeRune(key)
+	return r
+}
+
+func valueString(data interface{}) string {
+	switch v := data.(type) {
+	case []byte:
+		return strconv.Quote(string(v))
+	case string:
+		return strconv.Quote(v)
+	}
+	return fmt.Sprint(data)
+}
+
+// open opens the snapshot file at path. Unless create is true, the file
+// must exist.
+func open(path string, create bool) *backend.DB {
+	if _, err := os.Stat(path); err != nil && !create {
+		log.Fatal(err)
+	}
+	b, err := backend.Open(path, lockTimeout)
+	if err != nil {
+		log.Fatalf("opening %s: %v", path, err)
+	}
+	return b
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: azmodb <command> [flags] FILE [args]
+
+commands:
+  revs     list snapshot revisions
+  get      print the value of a key
+  range    print the keys in a range
+  dump     write all key/value revisions as JSON lines
+  load     read JSON lines and write a new snapshot revision
+  verify   check the integrity of all snapshot revisions
+  diff     compare two snapshot revisions
+  compact  copy the last snapshot revisions to a new file`)
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("azmodb: ")
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	cmd, args := os.Args[1], os.Args[2:]
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	snap := fs.Int64("snapshot", 0, "snapshot revision (default last)")
+	rev := fs.Int64("rev", 0, "database revision within the snapshot (default last)")
+	var from, to *string
+	var limit, keep *int
+	switch cmd {
+	case "range":
+		from = fs.String("from", "", "first key")
+		to = fs.String("to", "", "key following the last key")
+		limit = fs.Int("limit", 0, "maximum number of keys")
+	case "compact":
+		keep = fs.Int("keep", 1, "number of snapshot revisions to keep")
+	}
+	fs.Parse(args)
+	args = fs.Args()
+
+	need := map[string]int{
+		"revs": 1, "get": 2, "range": 1, "dump": 1, "load": 1,
+		"verify": 1, "diff": 3, "compact": 2,
+	}
+	n, found := need[cmd]
+	if !found {
+		usage()
+	}
+	if len(args) != n {
+		log.Fatalf("%s: expected %d arguments, have %d", cmd, n, len(args))
+	}
+
+	b := open(args[0], cmd == "load")
+	defer b.Close()
+
+	var err error
+	switch cmd {
+	case "revs":
+		err = listRevisions(b, os.Stdout)
+	case "get":
+		err = get(b, *snap, *rev, []byte(args[1]), os.Stdout)
+	case "range":
+		err = rangeKeys(b, *snap, *rev, []byte(*from), []byte(*to), *limit, os.Stdout)
+	case "dump":
+		err = dump(b, *snap, os.Stdout)
+	case "load":
+		var loaded int64
+		if loaded, err = load(b, os.Stdin); err == nil {
+			fmt.Printf("loaded snapshot revision %d\n", loaded)
+		}
+	case "verify":
+		err = verify(b, os.Stdout)
+	case "diff":
+		err = diff(b, args[1], args[2], os.Stdout)
+	case "compact":
+		err = compact(b, args[1], *keep)
+	}
+	if err != nil {
+		b.Close()
+		log.Fatalf("%s: %v", cmd, err)
+	}
+}
+
+func listRevisions(b *backend.DB, w io.Writer) error {
+	revs, err := b.Revisions()
+	if err != nil {
+		return err
+	}
+	for _, rev := range revs {
+		keys := 0
+		if err = b.Range(rev, func(_, _ []byte) error {
+			keys++
+			return nil
+		}); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%d\t%d keys\n", revNumber(rev), keys)
+	}
+	return nil
+}
+
+func get(b *backend.DB, snap, rev int64, key []byte, w io.Writer) error {
+	srev, err := snapshot(b, snap)
+	if err != nil {
+		return err
+	}
+
+	var value *block
+	err = forEach(b, srev, func(p pair) error {
+		if bytes.Equal(p.key, key) {
+			blk, found := p.at(rev)
+			if !found {
+				return errRevisionNotFound
+			}
+			value = &blk
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return errors.New("key not found")
+	}
+	fmt.Fprintf(w, "%s\t%d\n", valueString(value.Data), value.Rev)
+	return nil
+}
+
+var errStop = errors.New("stop")
+
+func rangeKeys(b *backend.DB, snap, rev int64, from, to []byte, limit int, w io.Writer) error {
+	srev, err := snapshot(b, snap)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	err = forEach(b, srev, func(p pair) error {
+		if bytes.Compare(p.key, from) < 0 {
+			return nil
+		}
+		if len(to) > 0 && bytes.Compare(p.key, to) >= 0 {
+			return errStop
+		}
+		blk, found := p.at(rev)
+		if !found {
+			return nil
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\n", keyString(p.key), valueString(blk.Data), blk.Rev)
+		if count++; limit > 0 && count >= limit {
+			return errStop
+		}
+		return nil
+	})
+	if err == errStop {
+		err = nil
+	}
+	return err
+}
+
+func verify(b *backend.DB, w io.Writer) error {
+	if err := b.Verify(); err != nil {
+		return err
+	}
+	revs, err := b.Revisions()
+	if err != nil {
+		return err
+	}
+
+	keys := 0
+	for _, srev := range revs {
+		n := revNumber(srev)
+		var last []byte
+		err = forEach(b, srev, func(p pair) error {
+			if last != nil && bytes.Compare(last, p.key) >= 0 {
+				return fmt.Errorf("key %s: keys out of order", keyString(p.key))
+			}
+			last = p.key
+			prev := int64(0)
+			for _, blk := range p.blocks {
+				if blk.Rev <= prev || blk.Rev > n {
+					return fmt.Errorf("key %s: invalid revision %d", keyString(p.key), blk.Rev)
+				}
+				prev = blk.Rev
+			}
+			keys++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("snapshot revision %d: %v", n, err)
+		}
+	}
+	fmt.Fprintf(w, "ok: %d snapshot revisions, %d keys\n", len(revs), keys)
+	return nil
+}
+
+func diff(b *backend.DB, rev1, rev2 string, w io.Writer) error {
+	var pairs [2]map[string]block
+	for i, arg := range []string{rev1, rev2} {
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid revision %q", arg)
+		}
+		srev, err := snapshot(b, n)
+		if err != nil {
+			return err
+		}
+		pairs[i] = make(map[string]block)
+		if err = forEach(b, srev, func(p pair) error {
+			pairs[i][string(p.key)], _ = p.at(0)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	keys := []string{}
+	for _, m := range pairs {
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for i, key := range keys {
+		if i > 0 && keys[i-1] == key {
+			continue
+		}
+		a, inA := pairs[0][key]
+		b, inB := pairs[1][key]
+		switch {
+		case !inA:
+			fmt.Fprintf(w, "+ %s\n", keyString([]byte(key)))
+		case !inB:
+			fmt.Fprintf(w, "- %s\n", keyString([]byte(key)))
+		case a.Rev != b.Rev:
+			fmt.Fprintf(w, "~ %s\n", keyString([]byte(key)))
+		}
+	}
+	return nil
+}
+
+func compact(b *backend.DB, dst string, keep int) error {
+	if keep < 1 {
+		return errors.New("must keep at least one revision")
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	revs, err := b.Revisions()
+	if err != nil {
+		return err
+	}
+	if len(revs) > keep {
+		revs = revs[len(revs)-keep:]
+	}
+
+	out, err := backend.Open(dst, lockTimeout)
+	if err != nil {
+		return err
+	}
+	for _, rev := range revs {
+		if err = copyRevision(b, out, rev); err != nil {
+			out.Close()
+			os.Remove(dst)
+			return err
+		}
+	}
+	return out.Close()
+}
+
+func copyRevision(src, dst *backend.DB, rev backend.Revision) error {
+	batch, err := dst.Batch(rev)
+	if err != nil {
+		return err
+	}
+	if err = src.Range(rev, batch.Put); err != nil {
+		batch.Rollback()
+		return err
+	}
+	return batch.Close()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/azmodb/db"
+	"github.com/azmodb/db/backend"
+)
+
+// writeSnapshots writes the snapshot revisions 3 and 6 of a database
+// in which the following transactions have been committed:
+//
+//	put a=1, put b="two", put c=[]byte("three")
+//	put a=10, delete b, put d=4.5
+func writeSnapshots(t *testing.T, path string) {
+	b := openTest(t, path)
+	defer b.Close()
+
+	for _, snap := range []struct {
+		rev   int64
+		pairs map[string][]block
+	}{
+		{3, map[string][]block{
+			"a": {{int64(1), 1}},
+			"b": {{"two", 2}},
+			"c": {{[]byte("three"), 3}},
+		}},
+		{6, map[string][]block{
+			"a": {{int64(1), 1}, {int64(10), 4}},
+			"c": {{[]byte("three"), 3}},
+			"d": {{4.5, 6}},
+		}},
+	} {
+		batch, err := b.Batch(revision(snap.rev))
+		if err != nil {
+			t.Fatalf("batch: %v", err)
+		}
+		for key, blocks := range snap.pairs {
+			value, err := encodeBlocks(blocks)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if err = batch.Put([]byte(key), value); err != nil {
+				t.Fatalf("put: %v", err)
+			}
+		}
+		if err = batch.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+	}
+}
+
+func openTest(t *testing.T, path string) *backend.DB {
+	b, err := backend.Open(path, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return b
+}
+
+func TestCommands(t *testing.T) {
+	defer os.RemoveAll("test_cmd.db")
+	writeSnapshots(t, "test_cmd.db")
+	b := openTest(t, "test_cmd.db")
+	defer b.Close()
+
+	out := &bytes.Buffer{}
+	check := func(name string, err error, want string) {
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if out.String() != want {
+			t.Fatalf("%s: expected %q, have %q", name, want, out.String())
+		}
+		out.Reset()
+	}
+
+	check("revs", listRevisions(b, out), "3\t3 keys\n6\t3 keys\n")
+	check("get", get(b, 0, 0, []byte("a"), out), "10\t4\n")
+	check("get", get(b, 0, 3, []byte("a"), out), "1\t1\n")
+	check("get", get(b, 3, 0, []byte("b"), out), "\"two\"\t2\n")
+	check("range", rangeKeys(b, 0, 0, []byte("a"), []byte("d"), 0, out),
+		"a\t10\t4\nc\t\"three\"\t3\n")
+	check("range", rangeKeys(b, 0, 0, nil, nil, 1, out), "a\t10\t4\n")
+	check("diff", diff(b, "3", "6", out), "~ a\n- b\n+ d\n")
+	check("verify", verify(b, out), "ok: 2 snapshot revisions, 6 keys\n")
+
+	if err := get(b, 0, 0, []byte("missing"), out); err == nil {
+		t.Fatalf("get: expected key not found error")
+	}
+	if err := get(b, 42, 0, []byte("a"), out); err != errRevisionNotFound {
+		t.Fatalf("get: expected errRevisionNotFound, have %v", err)
+	}
+}
+
+func TestDumpLoad(t *testing.T) {
+	defer os.RemoveAll("test_cmd_dump.db")
+	defer os.RemoveAll("test_cmd_load.db")
+	writeSnapshots(t, "test_cmd_dump.db")
+
+	src := openTest(t, "test_cmd_dump.db")
+	defer src.Close()
+	dumped := &bytes.Buffer{}
+	if err := dump(src, 0, dumped); err != nil {
+		t.Fatalf("dump: %v", err)
+	}
+	if n := strings.Count(dumped.String(), "\n"); n != 4 {
+		t.Fatalf("dump: expected 4 records, have %d", n)
+	}
+
+	dst := openTest(t, "test_cmd_load.db")
+	defer dst.Close()
+	rev, err := load(dst, bytes.NewReader(dumped.Bytes()))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if rev != 6 {
+		t.Fatalf("load: expected snapshot revision 6, have %d", rev)
+	}
+
+	reloaded := &bytes.Buffer{}
+	if err = dump(dst, 0, reloaded); err != nil {
+		t.Fatalf("dump: %v", err)
+	}
+	if reloaded.String() != dumped.String() {
+		t.Fatalf("load: expected\n%s\nhave\n%s", dumped, reloaded)
+	}
+	dst.Close()
+
+	// the loaded file must be readable by the database
+	d, err := db.Load("test_cmd_load.db", 0)
+	if err != nil {
+		t.Fatalf("load database: %v", err)
+	}
+	if data, _, _, err := d.Get([]byte("c"), 0, false); err != nil || string(data.([]byte)) != "three" {
+		t.Fatalf("get: expected three, have %v (%v)", data, err)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	defer os.RemoveAll("test_cmd_compact.db")
+	defer os.RemoveAll("test_cmd_compacted.db")
+	writeSnapshots(t, "test_cmd_compact.db")
+
+	b := openTest(t, "test_cmd_compact.db")
+	defer b.Close()
+	if err := compact(b, "test_cmd_compacted.db", 1); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if err := compact(b, "test_cmd_compacted.db", 1); err == nil {
+		t.Fatalf("compact: expected existing destination error")
+	}
+
+	c := openTest(t, "test_cmd_compacted.db")
+	defer c.Close()
+	out := &bytes.Buffer{}
+	if err := listRevisions(c, out); err != nil {
+		t.Fatalf("revs: %v", err)
+	}
+	if out.String() != "6\t3 keys\n" {
+		t.Fatalf("compact: expected revision 6, have %q", out.String())
+	}
+	if err := c.Verify(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+module github.com/azmodb/db
+
+go 1.25.0
+
+require (
+	github.com/azmodb/llrb v0.0.0-00010101000000-000000000000 // upstream has no releases, pin with go get github.com/azmodb/llrb@master
+	github.com/golang/snappy v1.0.0
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/term v0.42.0
+	google.golang.org/grpc v1.82.1
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	golang.org/x/net v0.53.0 // indirect
+	golang.org/x/sys v0.43.0 // indirect
+	golang.org/x/text v0.36.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260414002931-afd174a4e478 // indirect
+)
@@ -0,0 +1,72 @@
+package db
+
+// Validator is called with a view of the database as it would be after
+// a transaction has been committed and the changes of the transaction.
+// If a validator returns an error, the transaction is rolled back and
+// Commit returns a *ValidationError wrapping the error.
+//
+// Validators are called while the writer lock is held and must not
+// start transactions.
+type Validator func(r *Reader, c Commit) error
+
+// ValidationError describes a transaction rejected by a validator.
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the error of the validator.
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+type validator struct {
+	fn Validator
+}
+
+// AddValidator registers a validator for all subsequently committed
+// transactions. Transactions replicated from a primary are not
+// validated. It returns a function removing the validator.
+func (db *DB) AddValidator(v Validator) (remove func()) {
+	h := &validator{fn: v}
+	db.writer.Lock()
+	db.validators = append(db.validators, h)
+	db.writer.Unlock()
+
+	return func() {
+		db.writer.Lock()
+		validators := db.validators[:0:0]
+		for _, o := range db.validators {
+			if o != h {
+				validators = append(validators, o)
+			}
+		}
+		db.validators = validators
+		db.writer.Unlock()
+	}
+}
+
+// commitHook is an observer calling a user supplied function.
+type commitHook struct {
+	fn func(c Commit)
+}
+
+func (h *commitHook) committed(rec record) { h.fn(newCommit(rec)) }
+
+func (h *commitHook) replaced(rec record) { h.fn(newCommit(rec)) }
+
+// AddCommitHook registers fn to be called with every subsequently
+// committed transaction, in revision order. Fn is called while the
+// writer lock is held and must not start transactions. It returns a
+// function removing the hook.
+func (db *DB) AddCommitHook(fn func(c Commit)) (remove func()) {
+	h := &commitHook{fn: fn}
+	db.writer.Lock()
+	db.hooks = append(db.hooks, h)
+	db.writer.Unlock()
+
+	return func() {
+		db.writer.Lock()
+		db.removeHook(h)
+		db.writer.Unlock()
+	}
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	errSchema := errors.New("user values must be strings")
+	errRef := errors.New("dangling reference")
+
+	db := New()
+	removeSchema := db.AddValidator(func(_ *Reader, c Commit) error {
+		for _, ch := range c.Changes {
+			if _, ok := ch.Data.(string); bytes.HasPrefix(ch.Key, []byte("user/")) && !ch.Deleted && !ok {
+				return errSchema
+			}
+		}
+		return nil
+	})
+	db.AddValidator(func(r *Reader, c Commit) error {
+		if data, _, err := r.Get([]byte("ref"), 0, false); err == nil {
+			if _, _, err = r.Get([]byte(data.(string)), 0, false); err != nil {
+				return errRef
+			}
+		}
+		return nil
+	})
+
+	commits := []Commit{}
+	removeHook := db.AddCommitHook(func(c Commit) { commits = append(commits, c) })
+
+	for i, test := range []struct {
+		fn  func(tx *Txn)
+		err error
+	}{
+		{func(tx *Txn) { tx.Put([]byte("user/a"), "alice", false) }, nil},
+		{func(tx *Txn) { tx.Put([]byte("user/b"), 42, false) }, errSchema},
+		{func(tx *Txn) { tx.Put([]byte("ref"), "user/b", false) }, errRef},
+		{func(tx *Txn) { tx.Put([]byte("ref"), "user/a", false) }, nil},
+		{func(tx *Txn) { tx.Delete([]byte("user/a")) }, errRef},
+		{func(tx *Txn) {}, nil},
+	} {
+		tx := db.Txn()
+		test.fn(tx)
+		if err := tx.Commit(); !errors.Is(err, test.err) {
+			t.Fatalf("validator #%d: expected error %v, have %v", i, test.err, err)
+		}
+	}
+	if db.Rev() != 2 {
+		t.Fatalf("validator: expected revision 2, have %d", db.Rev())
+	}
+	if len(commits) != 2 || commits[1].From != 2 || string(commits[1].Changes[0].Key) != "ref" {
+		t.Fatalf("commit hook: unexpected commits %+v", commits)
+	}
+
+	removeSchema()
+	removeHook()
+	if err := db.Update(func(tx *Txn) error {
+		_, err := tx.Put([]byte("user/b"), 42, false)
+		return err
+	}); err != nil {
+		t.Fatalf("validator: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("commit hook: expected removed hook, have %d commits", len(commits))
+	}
+}
@@ -5,17 +5,19 @@
 //
 // The database provides the following:
 //
-//	* Multi-Version Concurrency Control (MVCC) - By leveraging immutable LLRB
-//	  trees the database is able to support any number of concurrent readers
-//	  without locking,  and allows a writer to make progress.
+//   - Multi-Version Concurrency Control (MVCC) - By leveraging immutable LLRB
+//     trees the database is able to support any number of concurrent readers
+//     without locking,  and allows a writer to make progress.
 //
-//	* Transaction Support - The database allows for rich transactions, in
-//	  which multiple objects are inserted, updated or deleted. The database
-//	  provides atomicity and isolation in ACID terminology, such that until
-//	  commit the updates are not visible.
+//   - Transaction Support - The database allows for rich transactions, in
+//     which multiple objects are inserted, updated or deleted. The database
+//     provides atomicity and isolation in ACID terminology, such that until
+//     commit the updates are not visible.
 package db
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"reflect"
 	"sync"
@@ -50,6 +52,22 @@ const (
 
 	// ErrInvertedRange is returned when a inverted range is supplied.
 	ErrInvertedRange = perror("inverted range")
+
+	// ErrReadOnly is returned when trying to update a read-only
+	// database.
+	ErrReadOnly = perror("database is read-only")
+
+	// ErrInvalidSavepoint is returned when rolling back to a savepoint
+	// which does not belong to the transaction or has been discarded.
+	ErrInvalidSavepoint = perror("invalid savepoint")
+
+	// ErrSlowConsumer is the error returned by a notifier using the
+	// CancelSlow policy when its queue overflows.
+	ErrSlowConsumer = perror("notifier queue overflow")
+
+	// ErrTxnClosed is returned when committing a transaction which has
+	// already been committed or rolled back.
+	ErrTxnClosed = perror("transaction is closed")
 )
 
 type perror string
@@ -60,21 +78,56 @@ func (e perror) Error() string { return string(e) }
 // All access is performed through a transaction which can be obtained
 // through the database.
 type DB struct {
-	writer  sync.Mutex // exclusive writer transaction
+	writer  *writerLock // exclusive writer transaction
 	tree    unsafe.Pointer
 	backend backend.Backend
+
+	snapshotter sync.Mutex // serializes snapshots
+	persisted   int64      // last revision written to the backend
+
+	readOnly bool       // rejects updates, set by followers
+	hooks    []observer // commit observers, protected by writer
+	keys     keyStreams // persistent notifiers
+	single   bool       // one revision per transaction, protected by writer
+
+	validators []*validator // protected by writer
+	merges     mergeRegistry
+}
+
+// observer is notified of every committed transaction. Observers are
+// called in revision order while the writer lock is held.
+type observer interface {
+	committed(rec record)
+
+	// replaced is called when a follower database has been caught up
+	// from a snapshot. Rec holds the net changes of all transactions
+	// covered by the snapshot.
+	replaced(rec record)
+}
+
+// removeHook removes a commit observer. The caller must hold the
+// writer lock.
+func (db *DB) removeHook(o observer) {
+	hooks := db.hooks[:0:0]
+	for _, h := range db.hooks {
+		if h != o {
+			hooks = append(hooks, h)
+		}
+	}
+	db.hooks = hooks
 }
 
 type tree struct {
 	root *llrb.Tree
 	rev  int64
+	len  int64 // number of key/value pairs
 }
 
 func newDB(t *tree) *DB {
 	if t == nil {
 		t = &tree{root: &llrb.Tree{}}
 	}
-	return &DB{tree: unsafe.Pointer(t)}
+	return &DB{tree: unsafe.Pointer(t), writer: newWriterLock()}
 }
 
 // Load reloads the immutable, consistent, in-memory key/value database
@@ -89,12 +142,15 @@ func Load(path string, timeout time.Duration, opts ...backend.Option) (*DB, erro
 		return nil, err
 	}
 	db.backend = backend
+	db.persisted = db.Rev()
 	return db, nil
 }
 
 // New returns an immutable, consistent, in-memory key/value database.
 func New() *DB { return newDB(nil) }
 
+var zeroRevision = backend.Revision{}
+
 // reload reloads the immutable, consistent, in-memory key/value database
 // from the underlying backend.
 func reload(backend backend.Backend) (*DB, error) {
@@ -102,6 +158,9 @@ func reload(backend backend.Backend) (*DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	if rev == zeroRevision { // no snapshot has been written yet
+		return newDB(nil), nil
+	}
 
 	tree := &tree{
 		rev:  int64(binary.BigEndian.Uint64(rev[:])),
@@ -124,6 +183,7 @@ func reload(backend backend.Backend) (*DB, error) {
 		copy(p.key, key)
 
 		txn.Insert(p)
+		tree.len++
 		return err
 	})
 	if err != nil {
@@ -134,14 +194,64 @@ func reload(backend backend.Backend) (*DB, error) {
 	return newDB(tree), nil
 }
 
+// SnapshotProgress describes the progress of a running snapshot.
+type SnapshotProgress struct {
+	Pairs int64 // key/value pairs written
+	Bytes int64 // encoded bytes written
+	Total int64 // estimated total number of key/value pairs
+}
+
+// SnapshotOptions configures a snapshot.
+type SnapshotOptions struct {
+	// Progress, if not nil, is called every Interval written key/value
+	// pairs and once after all pairs have been written.
+	Progress func(SnapshotProgress)
+
+	// Interval is the number of key/value pairs written between two
+	// progress reports. If Interval <= 0 a default interval is used.
+	Interval int64
+}
+
+const defaultProgressInterval = 1024
+
 // Snapshot writes the entire in-memory database to the underlying
-// backend.
-func (db *DB) Snapshot() (int64, error) {
-	return db.snapshot(db.backend)
+// backend. If the snapshot fails, the partially written revision is
+// discarded.
+//
+// Concurrent calls are serialized. If the current revision of the
+// database has already been written, Snapshot does nothing.
+//
+// Snapshot returns the revision of the snapshot, whether the snapshot
+// was written and an error if any.
+func (db *DB) Snapshot() (int64, bool, error) {
+	return db.SnapshotContext(context.Background(), SnapshotOptions{})
+}
+
+// SnapshotContext is like Snapshot but reports the progress to
+// opts.Progress. If ctx is done before the snapshot has been written,
+// the partially written revision is discarded and the context error is
+// returned.
+func (db *DB) SnapshotContext(ctx context.Context, opts SnapshotOptions) (int64, bool, error) {
+	db.snapshotter.Lock()
+	defer db.snapshotter.Unlock()
+
+	if rev := db.Rev(); rev == db.persisted {
+		return rev, false, nil
+	}
+
+	rev, err := db.snapshot(ctx, db.backend, opts)
+	if err != nil {
+		return rev, false, err
+	}
+	db.persisted = rev
+	return rev, true, nil
 }
 
-func (db *DB) snapshot(backend backend.Backend) (int64, error) {
+func (db *DB) snapshot(ctx context.Context, backend backend.Backend, opts SnapshotOptions) (int64, error) {
 	tree := db.load()
+	if err := ctx.Err(); err != nil {
+		return tree.rev, err
+	}
 
 	rev := [8]byte{}
 	binary.BigEndian.PutUint64(rev[:], uint64(tree.rev))
@@ -151,10 +261,28 @@ func (db *DB) snapshot(backend backend.Backend) (int64, error) {
 		return tree.rev, err
 	}
 
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	progress := SnapshotProgress{Total: tree.len}
+	report := func() {
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+
 	buf := newBuffer(nil)
 	tree.root.ForEach(func(elem llrb.Element) bool {
 		p := elem.(*pair)
 
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return true
+		default:
+		}
+
 		buf.Reset()
 		if err = encode(buf, p.blocks); err != nil {
 			return true
@@ -163,13 +291,23 @@ func (db *DB) snapshot(backend backend.Backend) (int64, error) {
 		if err = batch.Put(p.key, buf.Bytes()); err != nil {
 			return true
 		}
+
+		progress.Pairs++
+		progress.Bytes += int64(len(p.key) + buf.Len())
+		if progress.Pairs%interval == 0 {
+			report()
+		}
 		return false
 	})
 	if err != nil {
-		batch.Close()
+		batch.Rollback()
+		return tree.rev, err
+	}
+	if err = batch.Close(); err != nil {
 		return tree.rev, err
 	}
-	return tree.rev, batch.Close()
+	report()
+	return tree.rev, nil
 }
 
 func (db *DB) store(t *tree) {
@@ -187,19 +325,25 @@ func (db *DB) load() *tree {
 // Get returns the revision of the key/value pair, the current revision
 // of the database and an errors if any.
 func (db *DB) Get(key []byte, rev int64, equal bool) (interface{}, int64, int64, error) {
+	tree := db.load()
+	data, created, err := tree.get(key, rev, equal)
+	return data, created, tree.rev, err
+}
+
+// get retrieves the value for a key at revision rev from the tree.
+func (t *tree) get(key []byte, rev int64, equal bool) (interface{}, int64, error) {
 	match := newMatcher(key)
 	defer match.release()
-	tree := db.load()
 
-	if elem := tree.root.Get(match); elem != nil {
+	if elem := t.root.Get(match); elem != nil {
 		p := elem.(*pair)
 		b, found := lookup(p, rev, equal)
 		if found {
-			return b.Data, b.Rev, tree.rev, nil
+			return b.Data, b.Rev, nil
 		}
-		return nil, 0, tree.rev, ErrRevisionNotFound
+		return nil, 0, ErrRevisionNotFound
 	}
-	return nil, 0, tree.rev, ErrKeyNotFound
+	return nil, 0, ErrKeyNotFound
 }
 
 func lookup(p *pair, rev int64, equal bool) (block, bool) {
@@ -217,27 +361,32 @@ func lookup(p *pair, rev int64, equal bool) (block, bool) {
 }
 
 func rangeFunc(n *Notifier, rev int64, current int64, limit int32) llrb.Visitor {
+	count := int32(0)
 	return func(elem llrb.Element) bool {
 		p := elem.(*pair)
 		b, found := lookup(p, rev, false)
 		if found {
-			return !n.send(p.key, b.Data, b.Rev, current)
+			if !n.send(Event{Created: b.Rev, Current: current, Data: b.Data, Key: p.key}) {
+				return true
+			}
+			count++
+			return limit > 0 && count >= limit
 		}
 		return false // ignore revision not found error
 	}
 }
 
-func (db *DB) get(tree *tree, key []byte, rev int64) (*Notifier, int64, error) {
-	n := newNotifier(42, nil, 1)
+func (t *tree) notify(ctx context.Context, key []byte, rev int64) *Notifier {
+	n := newSingleNotifier(ctx)
 	go func() {
-		data, created, current, err := db.Get(key, rev, false)
+		data, created, err := t.get(key, rev, false)
 		if err != nil {
 			n.close(err)
 		} else {
-			n.send(key, data, created, current)
+			n.send(Event{Created: created, Current: t.rev, Data: data, Key: key})
 		}
 	}()
-	return n, tree.rev, nil
+	return n
 }
 
 // Range iterates over values stored in the database in the range at rev
@@ -255,21 +404,34 @@ func (db *DB) get(tree *tree, key []byte, rev int64) (*Notifier, int64, error) {
 // Range returns a notifier, the current revision of the database and an
 // error if any.
 func (db *DB) Range(from, to []byte, rev int64, limit int32) (*Notifier, int64, error) {
+	return db.RangeContext(context.Background(), from, to, rev, limit)
+}
+
+// RangeContext is like Range but cancels the notifier when ctx is
+// done. Pending events are discarded in that case.
+func (db *DB) RangeContext(ctx context.Context, from, to []byte, rev int64, limit int32) (*Notifier, int64, error) {
 	tree := db.load()
-	if compare(from, to) > 0 {
-		return nil, tree.rev, ErrInvertedRange
+	n, err := tree.rangeNotify(ctx, from, to, rev, limit)
+	return n, tree.rev, err
+}
+
+func (t *tree) rangeNotify(ctx context.Context, from, to []byte, rev int64, limit int32) (*Notifier, error) {
+	if to != nil && compare(from, to) > 0 {
+		return nil, ErrInvertedRange
 	}
 
 	if from != nil && to == nil { // simulate get request with equal == false
-		return db.get(tree, from, rev)
+		return t.notify(ctx, from, rev), nil
 	}
 
-	n := newNotifier(42, nil, defaultNotifierCapacity)
+	// The range is produced by its own goroutine and must not lose
+	// results, so it waits for the consumer.
+	n := newNotifier(ctx, 42, nil, NotifierOptions{Policy: Block})
 	go func() {
-		defer n.Cancel() // in any case cancel the infinte event queue
+		defer n.Cancel() // in any case cancel the event queue
 
 		if from == nil && to == nil { // foreach request
-			tree.root.ForEach(rangeFunc(n, rev, tree.rev, limit))
+			t.root.ForEach(rangeFunc(n, rev, t.rev, limit))
 			return
 		}
 
@@ -278,10 +440,9 @@ func (db *DB) Range(from, to []byte, rev int64, limit int32) (*Notifier, int64,
 			lo.release()
 			hi.release()
 		}()
-		tree.root.Range(lo, hi, rangeFunc(n, rev, tree.rev, limit))
+		t.root.Range(lo, hi, rangeFunc(n, rev, t.rev, limit))
 	}()
-
-	return n, tree.rev, nil
+	return n, nil
 }
 
 // Rev returns the current revision of the database.
@@ -291,15 +452,33 @@ func (db *DB) Rev() int64 {
 }
 
 // Watch returns a notifier for a key. If the key does not exist it
-// returns an error.
+// returns an error. If the consumer falls behind, the oldest queued
+// events are dropped; writers never wait for the notifier.
 func (db *DB) Watch(key []byte) (*Notifier, int64, error) {
+	return db.WatchWith(key, NotifierOptions{})
+}
+
+// WatchWith is like Watch but configures the capacity and overflow
+// policy of the notifier queue.
+func (db *DB) WatchWith(key []byte, opts NotifierOptions) (*Notifier, int64, error) {
+	return db.WatchContext(context.Background(), key, opts)
+}
+
+// WatchContext is like WatchWith but cancels the notifier when ctx is
+// done. Pending events are discarded in that case.
+func (db *DB) WatchContext(ctx context.Context, key []byte, opts NotifierOptions) (*Notifier, int64, error) {
+	if opts.Persistent {
+		n := db.keys.Register(ctx, key, opts)
+		return n, db.Rev(), nil
+	}
+
 	match := newMatcher(key)
 	defer match.release()
 	tree := db.load()
 
 	if elem := tree.root.Get(match); elem != nil {
 		p := elem.(*pair)
-		return p.stream.Register(), tree.rev, nil
+		return p.stream.Register(ctx, opts), tree.rev, nil
 	}
 	return nil, tree.rev, ErrKeyNotFound
 }
@@ -309,16 +488,101 @@ func (db *DB) Watch(key []byte) (*Notifier, int64, error) {
 // the calls to block and be serialized until the current transaction
 // finishes.
 func (db *DB) Txn() *Txn {
+	tx := db.txn()
+	tx.readOnly = db.readOnly
+	return tx
+}
+
+// TxnContext is like Txn but returns the context error if ctx is done
+// before the current transaction finishes.
+func (db *DB) TxnContext(ctx context.Context) (*Txn, error) {
+	if err := db.writer.LockContext(ctx); err != nil {
+		return nil, err
+	}
+	tx := db.newTxn()
+	tx.readOnly = db.readOnly
+	return tx, nil
+}
+
+// TryTxn starts a new batch transaction if no other batch transaction
+// is running. It reports whether the transaction has been started.
+func (db *DB) TryTxn() (*Txn, bool) {
+	if !db.writer.TryLock() {
+		return nil, false
+	}
+	tx := db.newTxn()
+	tx.readOnly = db.readOnly
+	return tx, true
+}
+
+// Update executes fn within a managed transaction. If fn returns nil,
+// the transaction is committed. If fn returns an error or panics, the
+// transaction is rolled back and the error is returned or the panic
+// is propagated. Fn must not commit or rollback the transaction.
+func (db *DB) Update(fn func(tx *Txn) error) error {
+	tx := db.Txn()
+	tx.managed = true
+	defer tx.rollback() // does nothing after commit
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.commit().Err
+}
+
+func (db *DB) txn() *Txn {
 	db.writer.Lock()
+	return db.newTxn()
+}
+
+// newTxn returns a transaction. The caller must hold the writer lock.
+func (db *DB) newTxn() *Txn {
 	tree := db.load()
-	return &Txn{txn: tree.root.Txn(), rev: tree.rev, db: db}
+	return &Txn{
+		txn:    tree.root.Txn(),
+		rev:    tree.rev,
+		base:   tree.rev,
+		len:    tree.len,
+		db:     db,
+		single: db.single,
+	}
+}
+
+// SetSingleRevision sets whether all updates of a transaction share
+// one revision. By default every update of a transaction creates a new
+// revision, so that reading at an intermediate revision observes a
+// partially applied transaction. In single revision mode, reading at
+// any revision observes either all or none of the updates of a
+// transaction.
+func (db *DB) SetSingleRevision(enabled bool) {
+	db.writer.Lock()
+	db.single = enabled
+	db.writer.Unlock()
 }
 
 // Txn represents a batch transaction on the database.
 type Txn struct {
-	txn *llrb.Txn
-	rev int64
-	db  *DB
+	txn      *llrb.Txn
+	rev      int64
+	base     int64 // revision the transaction started at
+	len      int64
+	db       *DB
+	changes  []change
+	readOnly bool
+	managed  bool  // commit and rollback are performed by the owner
+	single   bool  // all updates share one revision
+	replay   bool  // applies replicated changes, skips validators
+	err      error // update error without a return value, see Delete
+
+	savepoints []*Savepoint // valid savepoints, oldest first
+}
+
+// next returns the revision of the next update.
+func (tx *Txn) next() int64 {
+	if tx.single && tx.rev > tx.base {
+		return tx.rev
+	}
+	return tx.rev + 1
 }
 
 // Updater is a function that operates on a key/value pair
@@ -330,10 +594,13 @@ type Updater func(data interface{}) interface{}
 //
 // It the key exists and the value data type differ it returns an error.
 func (tx *Txn) Update(key []byte, up Updater, tombstone bool) (int64, error) {
+	if tx.readOnly {
+		return tx.rev, ErrReadOnly
+	}
 	match := newMatcher(key)
 	defer match.release()
 
-	rev := tx.rev + 1
+	rev := tx.next()
 	var p *pair
 	if elem := tx.txn.Get(match); elem != nil {
 		p = elem.(*pair)
@@ -345,10 +612,17 @@ func (tx *Txn) Update(key []byte, up Updater, tombstone bool) (int64, error) {
 		p = p.insert(data, rev, tombstone)
 	} else {
 		p = newPair(key, up(nil), rev)
+		tx.len++
 	}
 	tx.txn.Insert(p)
 	tx.rev = rev
-	p.stream.Notify(p, rev)
+	tx.changes = append(tx.changes, change{
+		Key:       p.key,
+		Data:      p.last().Data,
+		Rev:       rev,
+		Tombstone: tombstone,
+		pair:      p,
+	})
 
 	return tx.rev, nil
 }
@@ -369,43 +643,286 @@ func (tx *Txn) Put(key []byte, data interface{}, tombstone bool) (int64, error)
 }
 
 // Delete removes a key/value pair and returns the current revision of the
-// database.
+// database. On a read-only database Delete does nothing and the
+// transaction fails with ErrReadOnly on commit.
 func (tx *Txn) Delete(key []byte) int64 {
+	if tx.readOnly {
+		tx.err = ErrReadOnly
+		return tx.rev
+	}
 	match := newMatcher(key)
 	defer match.release()
 
 	if elem := tx.txn.Get(match); elem != nil {
-		p := elem.(*pair)
-		tx.txn.Delete(p)
-		tx.rev++
-		p.stream.Notify(p, tx.rev)
-		p.stream.Cancel()
+		tx.delete(elem.(*pair))
 	}
 	return tx.rev
 }
 
+func (tx *Txn) delete(p *pair) {
+	tx.txn.Delete(p)
+	tx.len--
+	tx.rev = tx.next()
+	tx.changes = append(tx.changes, change{
+		Key:     p.key,
+		Rev:     tx.rev,
+		Deleted: true,
+		pair:    p,
+	})
+}
+
+// DeleteRange removes all key/value pairs in the interval [from, to],
+// see DB.Range for the from/to combinations. Watchers of the removed
+// keys are notified on commit.
+//
+// DeleteRange returns the number of removed keys, the current revision
+// of the database and an error if any.
+func (tx *Txn) DeleteRange(from, to []byte) (int, int64, error) {
+	if tx.readOnly {
+		return 0, tx.rev, ErrReadOnly
+	}
+	var pairs []*pair
+	err := tx.pending().visit(from, to, func(p *pair) bool {
+		pairs = append(pairs, p)
+		return false
+	})
+	if err != nil {
+		return 0, tx.rev, err
+	}
+	for _, p := range pairs {
+		tx.delete(p)
+	}
+	return len(pairs), tx.rev, nil
+}
+
+// DeletePrefix removes all key/value pairs whose key starts with
+// prefix. Watchers of the removed keys are notified on commit.
+//
+// DeletePrefix returns the number of removed keys, the current
+// revision of the database and an error if any.
+func (tx *Txn) DeletePrefix(prefix []byte) (int, int64, error) {
+	if tx.readOnly {
+		return 0, tx.rev, ErrReadOnly
+	}
+	var pairs []*pair
+	collect := func(p *pair) bool {
+		if bytes.HasPrefix(p.key, prefix) {
+			pairs = append(pairs, p)
+		}
+		return false
+	}
+
+	if end := prefixEnd(prefix); end != nil {
+		tx.pending().visit(prefix, end, collect)
+	} else { // prefix is empty or consists of 0xff bytes only
+		tx.pending().visit(nil, nil, collect)
+	}
+	for _, p := range pairs {
+		tx.delete(p)
+	}
+	return len(pairs), tx.rev, nil
+}
+
+// prefixEnd returns the smallest key greater than all keys starting
+// with prefix or nil if there is none.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// pending returns the tree of the transaction including all updates
+// made so far.
+func (tx *Txn) pending() *tree {
+	root := tx.txn.Commit()
+	tx.txn = root.Txn()
+	return &tree{root: root, rev: tx.rev, len: tx.len}
+}
+
+// Get returns the current value for a key within the transaction,
+// including uncommitted updates, and the revision of the value.
+func (tx *Txn) Get(key []byte) (interface{}, int64, error) {
+	match := newMatcher(key)
+	defer match.release()
+
+	if elem := tx.txn.Get(match); elem != nil {
+		b := elem.(*pair).last()
+		return b.Data, b.Rev, nil
+	}
+	return nil, 0, ErrKeyNotFound
+}
+
+// Savepoint represents the state of a transaction at a point in time,
+// see Txn.Savepoint.
+type Savepoint struct {
+	root    *llrb.Tree
+	rev     int64
+	len     int64
+	changes int // number of queued changes
+}
+
+// Savepoint returns the current state of the transaction. Updates made
+// after the savepoint can be discarded by RollbackTo without
+// abandoning the transaction.
+func (tx *Txn) Savepoint() *Savepoint {
+	sp := &Savepoint{
+		root:    tx.pending().root,
+		rev:     tx.rev,
+		len:     tx.len,
+		changes: len(tx.changes),
+	}
+	tx.savepoints = append(tx.savepoints, sp)
+	return sp
+}
+
+// RollbackTo discards all updates made after sp has been created,
+// including their pending notifications. Savepoints created after sp
+// are discarded, sp itself remains valid.
+func (tx *Txn) RollbackTo(sp *Savepoint) error {
+	i := len(tx.savepoints) - 1
+	for ; i >= 0 && tx.savepoints[i] != sp; i-- {
+	}
+	if i < 0 {
+		return ErrInvalidSavepoint
+	}
+	for j := i + 1; j < len(tx.savepoints); j++ {
+		tx.savepoints[j] = nil
+	}
+	tx.savepoints = tx.savepoints[:i+1]
+
+	tx.txn = sp.root.Txn()
+	tx.rev = sp.rev
+	tx.len = sp.len
+	for j := sp.changes; j < len(tx.changes); j++ {
+		tx.changes[j] = change{}
+	}
+	tx.changes = tx.changes[:sp.changes]
+	return nil
+}
+
 // Commit closes the transaction and writes all changes into the
-// database.
-func (tx *Txn) Commit() {
+// database. Watchers are notified after the changes become visible.
+// If a validator rejects the changes, the transaction is rolled back
+// and the validator error is returned. Committing a closed transaction
+// returns ErrTxnClosed.
+func (tx *Txn) Commit() error {
+	return tx.CommitWithResult().Err
+}
+
+// CommitResult describes the outcome of a transaction commit.
+type CommitResult struct {
+	From    int64    // revision of the first update
+	To      int64    // revision of the last update
+	Puts    int      // number of put and update operations
+	Deletes int      // number of delete operations
+	Keys    [][]byte // changed keys in order of their first change
+	NoOp    bool     // the transaction did not change anything
+	Err     error    // commit error, nothing has been committed
+}
+
+// CommitWithResult is like Commit but describes the committed
+// transaction. If the transaction did not change anything or has been
+// rejected, From and To are the revision the transaction has been
+// started at.
+func (tx *Txn) CommitWithResult() CommitResult {
+	if tx.managed {
+		panic("db: managed transaction commit not allowed")
+	}
+	return tx.commit()
+}
+
+func (tx *Txn) commit() CommitResult {
 	if tx.txn == nil { // already aborted or committed
-		return
+		return CommitResult{Err: ErrTxnClosed}
+	}
+
+	res := CommitResult{From: tx.base, To: tx.base}
+	if tx.err != nil {
+		res.Err = tx.err
+		tx.rollback()
+		return res
+	}
+	if len(tx.changes) == 0 {
+		res.NoOp = true
+		tx.rollback()
+		return res
+	}
+	rec := record{
+		From:    tx.changes[0].Rev,
+		To:      tx.rev,
+		Changes: tx.changes,
+	}
+	tree := &tree{root: tx.txn.Commit(), rev: tx.rev, len: tx.len}
+	if res.Err = tx.validate(tree, rec); res.Err != nil {
+		tx.rollback()
+		return res
 	}
 
-	tree := &tree{root: tx.txn.Commit(), rev: tx.rev}
 	tx.db.store(tree)
+	seen := make(map[string]bool, len(tx.changes))
+	for _, c := range tx.changes {
+		c.pair.stream.Notify(c.pair, c.Rev)
+		if c.Deleted {
+			c.pair.stream.Cancel()
+			res.Deletes++
+		} else {
+			res.Puts++
+		}
+		tx.db.keys.Notify(c)
+		if !seen[string(c.Key)] {
+			seen[string(c.Key)] = true
+			res.Keys = append(res.Keys, c.Key)
+		}
+	}
+	for _, o := range tx.db.hooks {
+		o.committed(rec)
+	}
+	res.From, res.To = rec.From, rec.To
+
+	tx.changes = nil
+	tx.savepoints = nil
 	tx.txn = nil
 	tx.rev = 0
 	tx.db.writer.Unlock() // release the writer lock
 	tx.db = nil
+	return res
+}
+
+func (tx *Txn) validate(tree *tree, rec record) error {
+	if tx.replay || len(tx.db.validators) == 0 {
+		return nil
+	}
+	r, c := &Reader{tree: tree}, newCommit(rec)
+	for _, v := range tx.db.validators {
+		if err := v.fn(r, c); err != nil {
+			return &ValidationError{Err: err}
+		}
+	}
+	return nil
 }
 
 // Rollback closes the transaction and ignores all previous updates.
+// Watchers are not notified.
 func (tx *Txn) Rollback() {
+	if tx.managed {
+		panic("db: managed transaction rollback not allowed")
+	}
+	tx.rollback()
+}
+
+func (tx *Txn) rollback() {
 	if tx.txn == nil { // already aborted or committed
 		return
 	}
 
 	tx.txn = nil
+	tx.changes = nil
+	tx.savepoints = nil
 	tx.db.writer.Unlock() // release the writer lock
 	tx.db = nil
 }
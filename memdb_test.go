@@ -2,6 +2,7 @@ package db
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -115,3 +116,333 @@ func TestCancelRange(t *testing.T) {
 	w, _, _ := db.Range(nil, nil, 0, 0)
 	w.Cancel()
 }
+
+func TestRangeLimit(t *testing.T) {
+	count := 100
+	db := New()
+	tx := db.Txn()
+	for i := 0; i < count; i++ {
+		key := []byte(fmt.Sprintf("k%.3d", i))
+		tx.Put(key, i, false)
+	}
+	tx.Commit()
+
+	for _, limit := range []int32{0, 1, 10, 100, 1000} {
+		w, _, _ := db.Range(nil, nil, 0, limit)
+		i := int32(0)
+		for ev := range w.Recv() {
+			if ev.Err() != nil {
+				break
+			}
+			i++
+		}
+		w.Cancel()
+
+		want := limit
+		if limit <= 0 || limit > int32(count) {
+			want = int32(count)
+		}
+		if i != want {
+			t.Fatalf("range: expected %d keys with limit %d, have %d", want, limit, i)
+		}
+	}
+}
+
+func TestRangeSingleKey(t *testing.T) {
+	db := New()
+	tx := db.Txn()
+	tx.Put([]byte("k"), 1, false)
+	tx.Commit()
+
+	w, _, _ := db.Range([]byte("k"), nil, 0, 0)
+	defer w.Cancel()
+	ev := <-w.Recv()
+	if ev.Err() != nil || ev.Data.(int) != 1 {
+		t.Fatalf("range: expected value 1, have %v %v", ev.Data, ev.Err())
+	}
+
+	w, _, _ = db.Range([]byte("x"), nil, 0, 0)
+	defer w.Cancel()
+	if ev = <-w.Recv(); ev.Err() != ErrKeyNotFound {
+		t.Fatalf("range: expected error %v, have %v", ErrKeyNotFound, ev.Err())
+	}
+}
+
+func TestSingleRevision(t *testing.T) {
+	db := New()
+	tx := db.Txn()
+	tx.Put([]byte("a"), 0, false)
+	tx.Put([]byte("c"), 0, false)
+	tx.Commit()
+
+	p := NewPrimary(db, 0)
+	defer p.Close()
+	f := NewFollower()
+	stop, _ := replicate(p, f)
+	defer stop()
+
+	db.SetSingleRevision(true)
+	tx = db.Txn()
+	for i, test := range []struct {
+		rev int64
+		fn  func() (int64, error)
+	}{
+		{3, func() (int64, error) { return tx.Put([]byte("a"), 1, false) }},
+		{3, func() (int64, error) { return tx.Put([]byte("b"), 2, false) }},
+		{3, func() (int64, error) { return tx.Put([]byte("a"), 3, false) }},
+		{3, func() (int64, error) { return tx.Delete([]byte("c")), nil }},
+	} {
+		if rev, err := test.fn(); err != nil || rev != test.rev {
+			t.Fatalf("single revision #%d: expected revision %d, have %d (%v)", i, test.rev, rev, err)
+		}
+	}
+	tx.Commit()
+	if db.Rev() != 3 {
+		t.Fatalf("single revision: expected revision 3, have %d", db.Rev())
+	}
+
+	data, created, _, err := db.Get([]byte("a"), 0, false)
+	if err != nil || data.(int) != 3 || created != 3 {
+		t.Fatalf("single revision: expected 3 at revision 3, have %v at %d (%v)", data, created, err)
+	}
+	if data, _, _, err = db.Get([]byte("a"), 2, false); err != nil || data.(int) != 0 {
+		t.Fatalf("single revision: expected 0 at revision 2, have %v (%v)", data, err)
+	}
+	if _, _, _, err = db.Get([]byte("b"), 2, false); err != ErrRevisionNotFound {
+		t.Fatalf("single revision: expected error %v, have %v", ErrRevisionNotFound, err)
+	}
+
+	waitRev(t, f.DB(), db.Rev())
+	testEqualDB(t, db, f.DB())
+}
+
+func TestUpdate(t *testing.T) {
+	db := New()
+	if err := db.Update(func(tx *Txn) error {
+		_, err := tx.Put([]byte("a"), 1, false)
+		return err
+	}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	want := errors.New("abort")
+	if err := db.Update(func(tx *Txn) error {
+		tx.Put([]byte("b"), 2, false)
+		return want
+	}); err != want {
+		t.Fatalf("update: expected error %v, have %v", want, err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("update: expected panic")
+			}
+		}()
+		db.Update(func(tx *Txn) error {
+			tx.Put([]byte("c"), 3, false)
+			tx.Commit() // not allowed
+			return nil
+		})
+	}()
+
+	if tx, ok := db.TryTxn(); !ok {
+		t.Fatalf("update: expected released writer lock")
+	} else {
+		tx.Rollback()
+	}
+	if db.Rev() != 1 {
+		t.Fatalf("update: expected revision 1, have %d", db.Rev())
+	}
+}
+
+func TestView(t *testing.T) {
+	db := New()
+	tx := db.Txn()
+	tx.Put([]byte("a"), 1, false)
+	tx.Put([]byte("b"), 2, false)
+	tx.Commit()
+
+	err := db.View(func(r *Reader) error {
+		tx := db.Txn()
+		tx.Put([]byte("a"), 10, false)
+		tx.Put([]byte("c"), 3, false)
+		tx.Commit()
+
+		if r.Rev() != 2 {
+			t.Fatalf("view: expected revision 2, have %d", r.Rev())
+		}
+		if data, _, err := r.Get([]byte("a"), 0, false); err != nil || data.(int) != 1 {
+			t.Fatalf("view: expected value 1, have %v (%v)", data, err)
+		}
+		n, err := r.Range(nil, nil, 0, 0)
+		if err != nil {
+			return err
+		}
+		defer n.Cancel()
+		count := 0
+		for ev := range n.Recv() {
+			if ev.Err() != nil {
+				break
+			}
+			if ev.Current != 2 {
+				t.Fatalf("view: expected current revision 2, have %d", ev.Current)
+			}
+			count++
+		}
+		if count != 2 {
+			t.Fatalf("view: expected 2 keys, have %d", count)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("view: %v", err)
+	}
+}
+
+func TestSavepoint(t *testing.T) {
+	db := New()
+	tx := db.Txn()
+	tx.Put([]byte("a"), 1, false)
+	tx.Commit()
+
+	w, _, _ := db.Watch([]byte("a"))
+	defer w.Cancel()
+
+	tx = db.Txn()
+	tx.Put([]byte("b"), 2, false)
+	sp1 := tx.Savepoint()
+	tx.Put([]byte("a"), 10, false)
+	tx.Put([]byte("c"), 3, false)
+	sp2 := tx.Savepoint()
+	tx.Delete([]byte("b"))
+
+	if err := tx.RollbackTo(sp1); err != nil {
+		t.Fatalf("rollback to: %v", err)
+	}
+	if err := tx.RollbackTo(sp2); err != ErrInvalidSavepoint {
+		t.Fatalf("rollback to: expected error %v, have %v", ErrInvalidSavepoint, err)
+	}
+	if _, _, err := tx.Get([]byte("c")); err != ErrKeyNotFound {
+		t.Fatalf("savepoint: expected error %v, have %v", ErrKeyNotFound, err)
+	}
+	if rev, _ := tx.Put([]byte("d"), 4, false); rev != 3 {
+		t.Fatalf("savepoint: expected revision 3, have %d", rev)
+	}
+	if err := tx.RollbackTo(sp1); err != nil { // savepoints can be reused
+		t.Fatalf("rollback to: %v", err)
+	}
+	tx.Commit()
+
+	if db.Rev() != 2 {
+		t.Fatalf("savepoint: expected revision 2, have %d", db.Rev())
+	}
+	for key, want := range map[string]interface{}{"a": 1, "b": 2, "c": nil, "d": nil} {
+		data, _, _, err := db.Get([]byte(key), 0, false)
+		if want == nil && err != ErrKeyNotFound || want != nil && data != want {
+			t.Fatalf("savepoint: expected %q = %v, have %v (%v)", key, want, data, err)
+		}
+	}
+	select {
+	case ev := <-w.Recv():
+		t.Fatalf("savepoint: unexpected notification %v", ev.Data)
+	default:
+	}
+	tx = db.Txn()
+	defer tx.Rollback()
+	if err := tx.RollbackTo(sp1); err != ErrInvalidSavepoint {
+		t.Fatalf("rollback to: expected error %v, have %v", ErrInvalidSavepoint, err)
+	}
+}
+
+func TestCommitResult(t *testing.T) {
+	db := New()
+	tx := db.Txn()
+	tx.Put([]byte("a"), 1, false)
+	tx.Put([]byte("b"), 2, false)
+	tx.Put([]byte("a"), 3, false)
+	tx.Delete([]byte("b"))
+	res := tx.CommitWithResult()
+	if res.Err != nil || res.NoOp || res.From != 1 || res.To != 4 || res.Puts != 3 || res.Deletes != 1 {
+		t.Fatalf("commit result: unexpected result %+v", res)
+	}
+	if fmt.Sprintf("%s", res.Keys) != "[a b]" {
+		t.Fatalf("commit result: expected keys [a b], have %s", res.Keys)
+	}
+
+	tx = db.Txn()
+	tx.Delete([]byte("missing"))
+	if res = tx.CommitWithResult(); !res.NoOp || res.From != 4 || res.To != 4 {
+		t.Fatalf("commit result: expected no-op at revision 4, have %+v", res)
+	}
+	if res = tx.CommitWithResult(); res.Err != ErrTxnClosed || res.NoOp {
+		t.Fatalf("commit result: expected %v for closed transaction, have %+v", ErrTxnClosed, res)
+	}
+	if err := tx.Commit(); err != ErrTxnClosed {
+		t.Fatalf("commit: expected %v for closed transaction, have %v", ErrTxnClosed, err)
+	}
+
+	want := errors.New("rejected")
+	remove := db.AddValidator(func(_ *Reader, _ Commit) error { return want })
+	defer remove()
+	tx = db.Txn()
+	tx.Put([]byte("c"), 5, false)
+	if res = tx.CommitWithResult(); !errors.Is(res.Err, want) || res.From != 4 || res.To != 4 {
+		t.Fatalf("commit result: expected error %v at revision 4, have %+v", want, res)
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	db := New()
+	tx := db.Txn()
+	for _, key := range []string{"t1/a", "t1/b", "t2/a", "t2/b", "u"} {
+		tx.Put([]byte(key), key, false)
+	}
+	tx.Commit()
+
+	w, _, _ := db.Watch([]byte("t1/a"))
+	defer w.Cancel()
+
+	tx = db.Txn()
+	tx.Put([]byte("t1/c"), "t1/c", false) // pending keys are removed too
+	if n, rev, err := tx.DeletePrefix([]byte("t1/")); err != nil || n != 3 || rev != 9 {
+		t.Fatalf("delete prefix: expected 3 keys at revision 9, have %d at %d (%v)", n, rev, err)
+	}
+	if n, rev, err := tx.DeleteRange([]byte("t2/b"), []byte("v")); err != nil || n != 2 || rev != 11 {
+		t.Fatalf("delete range: expected 2 keys at revision 11, have %d at %d (%v)", n, rev, err)
+	}
+	if _, _, err := tx.DeleteRange([]byte("b"), []byte("a")); err != ErrInvertedRange {
+		t.Fatalf("delete range: expected error %v, have %v", ErrInvertedRange, err)
+	}
+	tx.Commit()
+
+	r := db.Reader()
+	if count, _ := r.Count(nil, nil); count != 1 {
+		t.Fatalf("delete range: expected 1 key, have %d", count)
+	}
+	if _, _, err := r.Get([]byte("t2/a"), 0, false); err != nil {
+		t.Fatalf("delete range: expected remaining key t2/a: %v", err)
+	}
+
+	var ev Event
+	for ev = range w.Recv() {
+		if ev.Err() != nil {
+			break
+		}
+	}
+	if ev.Err() != PairDeleted {
+		t.Fatalf("delete range: expected error %v, have %v", PairDeleted, ev.Err())
+	}
+
+	for prefix, want := range map[string]string{
+		"":         "",
+		"a":        "b",
+		"a\xff":    "b",
+		"\xff\xff": "",
+		"ab\x00":   "ab\x01",
+	} {
+		if end := prefixEnd([]byte(prefix)); string(end) != want {
+			t.Fatalf("prefix end %q: expected %q, have %q", prefix, want, end)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sync"
+)
+
+// ErrUnknownMerge is returned when a merge operator has not been
+// registered.
+const ErrUnknownMerge = perror("unknown merge operator")
+
+// MergeOperator merges an operand into the value of a key. Existing is
+// nil if the key does not exist. The returned value must have the
+// same type as an existing value. Merge must not modify existing or
+// operand.
+type MergeOperator interface {
+	Merge(existing, operand interface{}) (interface{}, error)
+}
+
+// MergeFunc is an adapter to use an ordinary function as merge
+// operator.
+type MergeFunc func(existing, operand interface{}) (interface{}, error)
+
+// Merge implements the MergeOperator interface.
+func (fn MergeFunc) Merge(existing, operand interface{}) (interface{}, error) {
+	return fn(existing, operand)
+}
+
+// MergeError describes a failed merge of an operand into the value of
+// a key.
+type MergeError struct {
+	Op      string
+	Key     []byte
+	Value   interface{} // nil if the key does not exist
+	Operand interface{}
+	Err     error
+}
+
+func (e *MergeError) Error() string {
+	return fmt.Sprintf("merge %s %q: cannot merge %T into %T: %v",
+		e.Op, e.Key, e.Operand, e.Value, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *MergeError) Unwrap() error { return e.Err }
+
+// Built-in merge operators, registered on every database.
+var builtinMerges = map[string]MergeOperator{
+	// add adds a numeric operand to a numeric value of the same or
+	// another numeric type. The result has the type of the value. For
+	// integer values the operand must be integral and the result must
+	// fit the type of the value.
+	"add": MergeFunc(add),
+
+	// append appends a slice operand or a single element to a slice
+	// value.
+	"append": MergeFunc(func(existing, operand interface{}) (interface{}, error) {
+		return appendSlice(existing, operand, false)
+	}),
+
+	// union appends the elements of a slice operand or a single
+	// element to a slice value, skipping elements already present.
+	"union": MergeFunc(func(existing, operand interface{}) (interface{}, error) {
+		return appendSlice(existing, operand, true)
+	}),
+}
+
+type mergeRegistry struct {
+	mu  sync.RWMutex
+	ops map[string]MergeOperator
+}
+
+// RegisterMerge registers a merge operator under name, replacing any
+// previously registered operator including the built-in operators
+// add, append and union.
+func (db *DB) RegisterMerge(name string, op MergeOperator) {
+	db.merges.mu.Lock()
+	if db.merges.ops == nil {
+		db.merges.ops = make(map[string]MergeOperator)
+	}
+	db.merges.ops[name] = op
+	db.merges.mu.Unlock()
+}
+
+func (db *DB) mergeOperator(name string) (MergeOperator, error) {
+	db.merges.mu.RLock()
+	op, found := db.merges.ops[name]
+	db.merges.mu.RUnlock()
+	if !found {
+		if op, found = builtinMerges[name]; !found {
+			return nil, ErrUnknownMerge
+		}
+	}
+	return op, nil
+}
+
+// merge merges operand into the existing value of key.
+func (db *DB) merge(name string, key []byte, existing, operand interface{}) (interface{}, error) {
+	op, err := db.mergeOperator(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := op.Merge(existing, operand)
+	if err == nil && existing != nil && !typeEqual(existing, data) {
+		err = ErrIncompatibleValue
+	}
+	if err != nil {
+		return nil, &MergeError{Op: name, Key: key, Value: existing, Operand: operand, Err: err}
+	}
+	return data, nil
+}
+
+// Merge merges operand into the latest value of a key using the merge
+// operator registered under name. If the key does not exist, the
+// operand is merged into nil.
+//
+// Merge returns the current revision of the transaction and a
+// *MergeError if the operand cannot be merged.
+func (tx *Txn) Merge(key []byte, name string, operand interface{}) (int64, error) {
+	if tx.readOnly {
+		return tx.rev, ErrReadOnly
+	}
+	existing, _, err := tx.Get(key)
+	if err != nil && err != ErrKeyNotFound {
+		return tx.rev, err
+	}
+	data, err := tx.db.merge(name, key, existing, operand)
+	if err != nil {
+		return tx.rev, err
+	}
+	return tx.Put(key, data, false)
+}
+
+// Increment adds delta to the numeric value of a key, which may have
+// any integer or float type. If the key does not exist, it is created
+// with the int64 value delta. To create a counter of another type, use
+// Merge with the "add" operator and an operand of that type.
+func (tx *Txn) Increment(key []byte, delta int64) (int64, error) {
+	return tx.Merge(key, "add", delta)
+}
+
+// Decrement subtracts delta from the numeric value of a key, see
+// Increment. If the key does not exist, it is created with the int64
+// value -delta.
+func (tx *Txn) Decrement(key []byte, delta int64) (int64, error) {
+	if delta == math.MinInt64 { // -delta is not representable
+		return tx.rev, &MergeError{Op: "add", Key: key, Operand: delta, Err: errOutOfRange}
+	}
+	return tx.Merge(key, "add", -delta)
+}
+
+var (
+	errOutOfRange = fmt.Errorf("%w: result out of range", ErrIncompatibleValue)
+	errFraction   = fmt.Errorf("%w: fractional operand", ErrIncompatibleValue)
+)
+
+// add adds operand to existing. Integer values are computed exactly: a
+// fractional operand or a result which does not fit the type of the
+// value is an error.
+func add(existing, operand interface{}) (interface{}, error) {
+	o := reflect.ValueOf(operand)
+	if !isNumber(o) {
+		return nil, ErrIncompatibleValue
+	}
+	if existing == nil {
+		return operand, nil
+	}
+	v := reflect.ValueOf(existing)
+	if !isNumber(v) {
+		return nil, ErrIncompatibleValue
+	}
+
+	sum := reflect.New(v.Type()).Elem()
+	if isFloat(v) {
+		f := v.Float() + toFloat(o)
+		if sum.OverflowFloat(f) {
+			return nil, errOutOfRange
+		}
+		sum.SetFloat(f)
+		return sum.Interface(), nil
+	}
+
+	x, err := toInt(v)
+	if err != nil {
+		return nil, err
+	}
+	y, err := toInt(o)
+	if err != nil {
+		return nil, err
+	}
+	x.Add(x, y)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !x.IsInt64() || sum.OverflowInt(x.Int64()) {
+			return nil, errOutOfRange
+		}
+		sum.SetInt(x.Int64())
+	default:
+		if !x.IsUint64() || sum.OverflowUint(x.Uint64()) {
+			return nil, errOutOfRange
+		}
+		sum.SetUint(x.Uint64())
+	}
+	return sum.Interface(), nil
+}
+
+func isFloat(v reflect.Value) bool {
+	return v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch {
+	case isFloat(v):
+		return v.Float()
+	case v.CanInt():
+		return float64(v.Int())
+	}
+	return float64(v.Uint())
+}
+
+// toInt returns the exact integer value of a number.
+func toInt(v reflect.Value) (*big.Int, error) {
+	switch {
+	case v.CanInt():
+		return big.NewInt(v.Int()), nil
+	case v.CanUint():
+		return new(big.Int).SetUint64(v.Uint()), nil
+	}
+	f := v.Float()
+	if math.IsInf(f, 0) || math.IsNaN(f) || f != math.Trunc(f) {
+		return nil, errFraction
+	}
+	x, _ := big.NewFloat(f).Int(nil)
+	return x, nil
+}
+
+func isNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// appendSlice returns a new slice containing the elements of existing
+// followed by the elements of operand. If unique is true, elements
+// already present are skipped.
+func appendSlice(existing, operand interface{}, unique bool) (interface{}, error) {
+	o := reflect.ValueOf(operand)
+	if existing == nil {
+		if o.Kind() != reflect.Slice {
+			return nil, ErrIncompatibleValue
+		}
+		existing = reflect.MakeSlice(o.Type(), 0, 0).Interface()
+	}
+	v := reflect.ValueOf(existing)
+	if v.Kind() != reflect.Slice {
+		return nil, ErrIncompatibleValue
+	}
+
+	var elems []reflect.Value
+	switch {
+	case o.IsValid() && o.Type() == v.Type():
+		for i := 0; i < o.Len(); i++ {
+			elems = append(elems, o.Index(i))
+		}
+	case o.IsValid() && o.Type().AssignableTo(v.Type().Elem()):
+		elems = append(elems, o)
+	default:
+		return nil, ErrIncompatibleValue
+	}
+
+	result := reflect.MakeSlice(v.Type(), v.Len(), v.Len()+len(elems))
+	reflect.Copy(result, v)
+	for _, elem := range elems {
+		if unique && contains(result, elem) {
+			continue
+		}
+		result = reflect.Append(result, elem)
+	}
+	return result.Interface(), nil
+}
+
+func contains(s, elem reflect.Value) bool {
+	for i := 0; i < s.Len(); i++ {
+		if reflect.DeepEqual(s.Index(i).Interface(), elem.Interface()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	db := New()
+	tx := db.Txn()
+	tx.Put([]byte("int"), 1, false)
+	tx.Put([]byte("uint8"), uint8(10), false)
+	tx.Put([]byte("float"), 1.5, false)
+	tx.Put([]byte("list"), []string{"a"}, false)
+	tx.Put([]byte("string"), "x", false)
+	tx.Commit()
+
+	tx = db.Txn()
+	defer tx.Rollback()
+	for i, test := range []struct {
+		key     string
+		op      string
+		operand interface{}
+		want    interface{}
+		err     error
+	}{
+		{"int", "add", int64(41), 42, nil},
+		{"uint8", "add", -3, uint8(7), nil},
+		{"float", "add", 2, 3.5, nil},
+		{"new", "add", int64(5), int64(5), nil},
+		{"list", "append", "b", []string{"a", "b"}, nil},
+		{"list", "append", []string{"a", "c"}, []string{"a", "b", "a", "c"}, nil},
+		{"list", "union", []string{"a", "d", "d"}, []string{"a", "b", "a", "c", "d"}, nil},
+		{"set", "union", []int{1, 1, 2}, []int{1, 2}, nil},
+		{"string", "add", 1, "x", ErrIncompatibleValue},
+		{"int", "add", "1", 42, ErrIncompatibleValue},
+		{"list", "append", 1, nil, ErrIncompatibleValue},
+		{"int", "missing", 1, 42, ErrUnknownMerge},
+		{"int", "add", 0.5, 42, ErrIncompatibleValue},
+		{"int", "add", uint64(math.MaxUint64), 42, ErrIncompatibleValue},
+		{"int", "add", 8.0, 50, nil},
+		{"uint8", "add", 249, uint8(7), ErrIncompatibleValue},
+		{"uint8", "add", -8, uint8(7), ErrIncompatibleValue},
+		{"uint8", "add", 248, uint8(255), nil},
+		{"float", "add", uint8(1), 4.5, nil},
+	} {
+		_, err := tx.Merge([]byte(test.key), test.op, test.operand)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("merge #%d: expected error %v, have %v", i, test.err, err)
+		}
+		if test.err == ErrIncompatibleValue {
+			var merr *MergeError
+			if !errors.As(err, &merr) || string(merr.Key) != test.key || merr.Op != test.op {
+				t.Fatalf("merge #%d: expected merge error, have %#v", i, err)
+			}
+		}
+		if test.want == nil {
+			continue
+		}
+		if data, _, _ := tx.Get([]byte(test.key)); fmt.Sprintf("%T %v", data, data) != fmt.Sprintf("%T %v", test.want, test.want) {
+			t.Fatalf("merge #%d: expected %T %v, have %T %v", i, test.want, test.want, data, data)
+		}
+	}
+
+	tx.Put([]byte("uint"), uint(0), false)
+	if _, err := tx.Decrement([]byte("uint"), 1); !errors.Is(err, ErrIncompatibleValue) {
+		t.Fatalf("decrement: expected error %v, have %v", ErrIncompatibleValue, err)
+	}
+	if _, err := tx.Decrement([]byte("counter"), math.MinInt64); !errors.Is(err, ErrIncompatibleValue) {
+		t.Fatalf("decrement: expected error %v, have %v", ErrIncompatibleValue, err)
+	}
+
+	tx.Increment([]byte("counter"), 10)
+	tx.Decrement([]byte("counter"), 3)
+	if data, _, _ := tx.Get([]byte("counter")); data.(int64) != 7 {
+		t.Fatalf("increment: expected 7, have %v", data)
+	}
+
+	db.RegisterMerge("max", MergeFunc(func(existing, operand interface{}) (interface{}, error) {
+		if existing == nil || operand.(int) > existing.(int) {
+			return operand, nil
+		}
+		return existing, nil
+	}))
+	tx.Merge([]byte("int"), "max", 100)
+	tx.Merge([]byte("int"), "max", 50)
+	if data, _, _ := tx.Get([]byte("int")); data.(int) != 100 {
+		t.Fatalf("merge max: expected 100, have %v", data)
+	}
+}
+
+func TestOptimisticMerge(t *testing.T) {
+	key, workers, count := []byte("counter"), 8, 50
+	db := New()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < count; n++ {
+				tx := db.OptimisticTxn()
+				tx.Merge(key, "add", int64(1))
+				if _, err := tx.Commit(); err != nil {
+					t.Errorf("optimistic merge: %v", err) // merges never conflict
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if data, _, _, _ := db.Get(key, 0, false); data.(int64) != int64(workers*count) {
+		t.Fatalf("optimistic merge: expected counter %d, have %v", workers*count, data)
+	}
+
+	tx := db.OptimisticTxn()
+	tx.Merge(key, "add", int64(1))
+	tx.Merge(key, "add", int64(1))
+	if data, _, err := tx.Get(key); err != nil || data.(int64) != int64(workers*count+2) {
+		t.Fatalf("optimistic merge: expected %d, have %v (%v)", workers*count+2, data, err)
+	}
+	other := db.Txn()
+	other.Increment(key, 1)
+	other.Commit()
+	if _, err := tx.Commit(); err != ErrConflict { // the value has been read
+		t.Fatalf("optimistic merge: expected error %v, have %v", ErrConflict, err)
+	}
+	if err := tx.Merge(key, "missing", 1); err != ErrUnknownMerge {
+		t.Fatalf("optimistic merge: expected error %v, have %v", ErrUnknownMerge, err)
+	}
+}
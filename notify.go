@@ -1,38 +1,9 @@
 package db
 
-import "sync"
-
-func queue(in <-chan Event, out chan<- Event, capacity int) {
-	pending := make([]Event, 0, capacity) // avoid small allocations
-	defer func() {
-		for _, v := range pending {
-			out <- v
-		}
-		pending = nil
-		close(out)
-	}()
-
-	for {
-		if len(pending) == 0 {
-			v, ok := <-in
-			if !ok {
-				return
-			}
-			pending = append(pending, v)
-		}
-
-		select {
-		case v, ok := <-in:
-			if !ok {
-				return
-			}
-			pending = append(pending, v)
-
-		case out <- pending[0]:
-			pending = pending[1:]
-		}
-	}
-}
+import (
+	"context"
+	"sync"
+)
 
 // Event represents a database key or range search query result. This
 // structure must be kept immutable.
@@ -41,46 +12,193 @@ type Event struct {
 	Created int64
 	Current int64
 	Key     []byte
+	Deleted bool // the key has been deleted, see NotifierOptions.Persistent
 	err     error
 }
 
 // Err returns an error if any.
 func (e Event) Err() error { return e.err }
 
-const defaultNotifierCapacity = 64
+const (
+	// defaultNotifierCapacity bounds the queue of a notifier if no
+	// capacity is given. It is large enough to hold the events of a
+	// typical transaction, so that a consumer which keeps up does not
+	// lose events under the default DropOldest policy.
+	defaultNotifierCapacity = 1024
+
+	// initialNotifierCapacity avoids small allocations without
+	// allocating the full queue up front.
+	initialNotifierCapacity = 64
+)
+
+// OverflowPolicy determines what a notifier does with a new event when
+// its queue is full.
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest queued event. It is the default
+	// policy.
+	DropOldest OverflowPolicy = iota
+
+	// Coalesce discards all queued events for the same key, so that
+	// only the latest value of a key is delivered. If no event for the
+	// key is queued, the oldest queued event is discarded.
+	Coalesce
+
+	// CancelSlow cancels the notifier. The consumer receives the queued
+	// events followed by an ErrSlowConsumer event.
+	CancelSlow
+
+	// Block blocks the writer until the consumer has received an event.
+	// Events are published while the writer lock is held, so a stalled
+	// consumer stalls all writers of the database, including
+	// replication. Use it only for consumers which are known to keep up.
+	Block
+)
+
+// NotifierOptions configures a notifier.
+type NotifierOptions struct {
+	// Capacity is the maximum number of queued events. If Capacity <= 0
+	// a default capacity is used.
+	Capacity int
+
+	// Policy determines what happens to new events when the queue is
+	// full. The zero value is DropOldest; writers never wait for a
+	// consumer unless Block is set explicitly.
+	Policy OverflowPolicy
+
+	// Persistent registers the notifier on the key rather than on the
+	// current key/value pair. A persistent notifier receives an event
+	// with Deleted set when the key is deleted and keeps receiving
+	// events after the key has been created again. The key does not
+	// need to exist.
+	Persistent bool
+}
+
+// NotifierStats reports the state of a notifier queue.
+type NotifierStats struct {
+	Pending   int    // events waiting to be received
+	Dropped   uint64 // events discarded because the queue was full
+	Coalesced uint64 // events superseded by a newer event for the same key
+}
+
+// tracker, if not nil, is called when a notifier is created and when
+// it is shut down. Tests use it to report leaked notifiers.
+var tracker func(n *Notifier, live bool)
 
 // Notifier represents the database event notifier.
 type Notifier struct {
-	cancel func(*Notifier)
-	out    chan Event
-	in     chan Event
-	mu     sync.Mutex
-	id     int64
+	cancel  func(*Notifier)
+	stop    func() bool     // unregisters the context callback
+	done    <-chan struct{} // closed when the consumer is gone
+	out     chan Event
+	mu      sync.Mutex
+	cond    sync.Cond // signals queued events and free capacity
+	pending []Event
+	opts    NotifierOptions
+	stats   NotifierStats
+	single  bool // delivers at most one event without a queue
+	id      int64
 }
 
-func newNotifier(id int64, cancel func(*Notifier), capacity int) *Notifier {
+func newNotifier(ctx context.Context, id int64, cancel func(*Notifier), opts NotifierOptions) *Notifier {
 	if id <= 0 {
 		panic("watcher: cannot use id <= 0")
 	}
 	if cancel == nil {
 		cancel = func(_ *Notifier) {}
 	}
+	if opts.Capacity <= 0 {
+		opts.Capacity = defaultNotifierCapacity
+	}
 	n := &Notifier{
-		out:    make(chan Event, capacity),
-		in:     make(chan Event, capacity),
-		id:     id,
-		cancel: cancel,
+		out:     make(chan Event),
+		pending: make([]Event, 0, min(opts.Capacity, initialNotifierCapacity)),
+		opts:    opts,
+		id:      id,
+		cancel:  cancel,
 	}
+	n.cond.L = &n.mu
+	n.start(ctx)
+	go n.run()
+	return n
+}
 
-	if capacity > 1 {
-		go queue(n.in, n.out, capacity)
+// newSingleNotifier returns a notifier for a single result event. The
+// channel is closed after the result has been sent.
+func newSingleNotifier(ctx context.Context) *Notifier {
+	n := &Notifier{
+		out:    make(chan Event, 1),
+		single: true,
+		id:     42,
+		cancel: func(_ *Notifier) {},
 	}
+	n.start(ctx)
 	return n
 }
 
-func (n *Notifier) shutdown() {
-	close(n.in)
+// start cancels the notifier when ctx is done.
+func (n *Notifier) start(ctx context.Context) {
+	if tracker != nil {
+		tracker(n, true)
+	}
+	n.done = ctx.Done()
+	n.stop = func() bool { return false }
+	if n.done != nil {
+		n.stop = context.AfterFunc(ctx, n.Cancel)
+	}
+}
+
+// run delivers queued events until the notifier is shut down and the
+// queue is drained.
+func (n *Notifier) run() {
+	defer close(n.out)
+	for {
+		n.mu.Lock()
+		for len(n.pending) == 0 && n.id > 0 {
+			n.cond.Wait()
+		}
+		if len(n.pending) == 0 {
+			n.mu.Unlock()
+			return
+		}
+		ev := n.pending[0]
+		n.pending[0] = Event{}
+		n.pending = n.pending[1:]
+		n.cond.Broadcast()
+		n.mu.Unlock()
+
+		select {
+		case n.out <- ev:
+		case <-n.done: // nobody is listening anymore
+			return
+		}
+	}
+}
+
+// finish marks the notifier as shut down. The caller must hold n.mu.
+func (n *Notifier) finish() {
 	n.id = -1
+	n.stop()
+	if tracker != nil {
+		tracker(n, false)
+	}
+}
+
+// shutdown queues the final event. The caller must hold n.mu.
+func (n *Notifier) shutdown(err error) {
+	ev := Event{err: err}
+	n.finish()
+	if n.single {
+		select {
+		case n.out <- ev:
+		default: // unreceived result
+		}
+		close(n.out)
+		return
+	}
+	n.pending = append(n.pending, ev)
+	n.cond.Broadcast()
 }
 
 // Cancel cancel and close the notifier. It should not be reused.
@@ -90,47 +208,115 @@ func (n *Notifier) Cancel() {
 		n.mu.Unlock()
 		return
 	}
-	n.cancel(n)
-	n.in <- Event{err: NotifierCanceled}
-	n.shutdown()
+	n.shutdown(NotifierCanceled)
 	n.mu.Unlock()
+	n.cancel(n)
 }
 
-func (n *Notifier) send(key []byte, data interface{}, created, current int64) bool {
+// send queues an event. It reports false if the notifier has been
+// shut down.
+func (n *Notifier) send(ev Event) bool {
 	n.mu.Lock()
+	defer n.mu.Unlock()
 	if n.id <= 0 {
-		n.mu.Unlock()
 		return false
 	}
-	n.in <- Event{
-		Created: created,
-		Current: current,
-		Data:    data,
-		Key:     key,
+	if n.single { // the result is the only event
+		n.out <- ev
+		n.finish()
+		close(n.out)
+		return true
 	}
-	n.mu.Unlock()
+
+	for len(n.pending) >= n.opts.Capacity {
+		switch n.opts.Policy {
+		case Coalesce:
+			if n.coalesce(ev.Key) {
+				continue
+			}
+			fallthrough
+		case DropOldest:
+			n.pending[0] = Event{}
+			n.pending = n.pending[1:]
+			n.stats.Dropped++
+		case CancelSlow:
+			n.stats.Dropped++
+			n.shutdown(ErrSlowConsumer)
+			return false
+		default:
+			n.cond.Wait()
+			if n.id <= 0 {
+				return false
+			}
+		}
+	}
+	n.pending = append(n.pending, ev)
+	n.cond.Broadcast()
 	return true
 }
 
+// coalesce removes all queued events for key and reports whether any
+// event has been removed.
+func (n *Notifier) coalesce(key []byte) bool {
+	pending := n.pending[:0]
+	for _, ev := range n.pending {
+		if ev.err == nil && compare(ev.Key, key) == 0 {
+			n.stats.Coalesced++
+			continue
+		}
+		pending = append(pending, ev)
+	}
+	for i := len(pending); i < len(n.pending); i++ {
+		n.pending[i] = Event{}
+	}
+	removed := len(pending) < len(n.pending)
+	n.pending = pending
+	return removed
+}
+
 func (n *Notifier) close(err error) {
 	n.mu.Lock()
 	if n.id <= 0 {
 		n.mu.Unlock()
 		return
 	}
-	n.in <- Event{err: err}
-	n.shutdown()
+	n.shutdown(err)
 	n.mu.Unlock()
 }
 
 // Recv returns the receiving channel part.
 func (n *Notifier) Recv() <-chan Event { return n.out }
 
+// Next waits for the next event. It returns the event and its error,
+// or the context error if ctx is done first. After the final event has
+// been received Next returns NotifierCanceled.
+func (n *Notifier) Next(ctx context.Context) (Event, error) {
+	select {
+	case ev, ok := <-n.out:
+		if !ok {
+			return Event{err: NotifierCanceled}, NotifierCanceled
+		}
+		return ev, ev.err
+	case <-ctx.Done():
+		return Event{err: ctx.Err()}, ctx.Err()
+	}
+}
+
+// Stats returns the current queue statistics of the notifier.
+func (n *Notifier) Stats() NotifierStats {
+	n.mu.Lock()
+	stats := n.stats
+	stats.Pending = len(n.pending)
+	n.mu.Unlock()
+	return stats
+}
+
 type stream struct {
 	mu        sync.Mutex // protects watcher registry
 	notifiers map[int64]*Notifier
 	num       int64
 	running   bool
+	idle      func() // called after the last notifier has been removed
 }
 
 func (s *stream) init() {
@@ -148,25 +334,31 @@ func (s *stream) shutdown() {
 	s.running = false
 }
 
-func (s *stream) Register() *Notifier {
+func (s *stream) Register(ctx context.Context, opts NotifierOptions) *Notifier {
 	s.mu.Lock()
 	s.init()
 	s.num++
-	n := newNotifier(s.num, s.cancel, defaultNotifierCapacity)
-	s.notifiers[s.num] = n
+	id := s.num
+	n := newNotifier(ctx, id, func(n *Notifier) { s.cancel(id, n) }, opts)
+	s.notifiers[id] = n
 	s.mu.Unlock()
 	return n
 }
 
-func (s *stream) cancel(n *Notifier) {
+func (s *stream) cancel(id int64, n *Notifier) {
 	s.mu.Lock()
-	if _, found := s.notifiers[n.id]; found {
-		delete(s.notifiers, n.id)
-	}
-	if len(s.notifiers) == 0 {
-		s.shutdown()
+	idle := false
+	if s.notifiers[id] == n {
+		delete(s.notifiers, id)
+		if len(s.notifiers) == 0 {
+			s.shutdown()
+			idle = s.idle != nil
+		}
 	}
 	s.mu.Unlock()
+	if idle {
+		s.idle()
+	}
 }
 
 func (s *stream) Cancel() {
@@ -176,24 +368,100 @@ func (s *stream) Cancel() {
 		return
 	}
 
-	for _, n := range s.notifiers {
-		delete(s.notifiers, n.id)
+	for id, n := range s.notifiers {
+		delete(s.notifiers, id)
 		n.close(PairDeleted)
 	}
 	s.shutdown()
 	s.mu.Unlock()
 }
 
+// Notify sends the last value of p to all registered notifiers.
 func (s *stream) Notify(p *pair, current int64) {
+	b := p.last()
+	s.publish(Event{
+		Created: b.Rev,
+		Current: current,
+		Data:    b.Data,
+		Key:     p.key,
+	})
+}
+
+// publish sends ev to all registered notifiers. Slow notifiers block
+// the caller only if they use the Block overflow policy.
+func (s *stream) publish(ev Event) {
 	s.mu.Lock()
 	if !s.running {
 		s.mu.Unlock()
 		return
 	}
 
-	b := p.last()
-	for _, n := range s.notifiers {
-		n.send(p.key, b.Data, b.Rev, current)
+	for id, n := range s.notifiers {
+		if !n.send(ev) {
+			delete(s.notifiers, id) // canceled or slow consumer
+		}
+	}
+	idle := false
+	if len(s.notifiers) == 0 {
+		s.shutdown()
+		idle = s.idle != nil
+	}
+	s.mu.Unlock()
+	if idle {
+		s.idle()
+	}
+}
+
+// keyStreams holds the streams of persistent notifiers by key. Unlike
+// the stream of a key/value pair, these streams outlive the deletion
+// of a key.
+type keyStreams struct {
+	mu      sync.Mutex
+	streams map[string]*stream
+}
+
+func (k *keyStreams) Register(ctx context.Context, key []byte, opts NotifierOptions) *Notifier {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.streams == nil {
+		k.streams = make(map[string]*stream)
+	}
+
+	name := string(key)
+	s := k.streams[name]
+	if s == nil {
+		s = &stream{}
+		s.idle = func() { k.release(name, s) }
+		k.streams[name] = s
+	}
+	return s.Register(ctx, opts)
+}
+
+// release removes the stream of a key without notifiers.
+func (k *keyStreams) release(name string, s *stream) {
+	k.mu.Lock()
+	s.mu.Lock()
+	if !s.running && k.streams[name] == s {
+		delete(k.streams, name)
 	}
 	s.mu.Unlock()
+	k.mu.Unlock()
+}
+
+// Notify sends a committed change to the persistent notifiers of its
+// key.
+func (k *keyStreams) Notify(c change) {
+	k.mu.Lock()
+	s := k.streams[string(c.Key)]
+	k.mu.Unlock()
+	if s == nil {
+		return
+	}
+
+	ev := Event{Current: c.Rev, Key: c.Key, Deleted: c.Deleted}
+	if !c.Deleted {
+		ev.Created = c.Rev
+		ev.Data = c.Data
+	}
+	s.publish(ev)
 }
@@ -1,6 +1,64 @@
 package db
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sync"
+	"testing"
+	"time"
+)
+
+// leaks records the creation stacks of notifiers which have not been
+// shut down yet.
+var leaks = struct {
+	sync.Mutex
+	live map[*Notifier][]byte
+}{live: make(map[*Notifier][]byte)}
+
+func TestMain(m *testing.M) {
+	tracker = func(n *Notifier, live bool) {
+		leaks.Lock()
+		if live {
+			leaks.live[n] = debug.Stack()
+		} else {
+			delete(leaks.live, n)
+		}
+		leaks.Unlock()
+	}
+
+	code := m.Run()
+	if code == 0 && checkLeaks(time.Second) {
+		code = 1
+	}
+	os.Exit(code)
+}
+
+// checkLeaks reports notifiers that are still live after timeout.
+func checkLeaks(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		leaks.Lock()
+		n := len(leaks.live)
+		leaks.Unlock()
+		if n == 0 {
+			return false
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	leaks.Lock()
+	defer leaks.Unlock()
+	fmt.Fprintf(os.Stderr, "%d notifiers never canceled\n", len(leaks.live))
+	for _, stack := range leaks.live {
+		fmt.Fprintf(os.Stderr, "\n%s", stack)
+	}
+	return true
+}
 
 func TestBasicNotifier(t *testing.T) {
 	key, count := []byte("k"), 100
@@ -54,7 +112,7 @@ func TestBasicNotifier(t *testing.T) {
 
 func TestNotifierCancelByUser(t *testing.T) {
 	s := &stream{}
-	n := s.Register()
+	n := s.Register(context.Background(), NotifierOptions{})
 
 	n.Cancel()
 	if s.num != 0 {
@@ -79,7 +137,7 @@ func TestNotifierCancelByUser(t *testing.T) {
 
 func TestNotifierCanceld(t *testing.T) {
 	s := &stream{}
-	n := s.Register()
+	n := s.Register(context.Background(), NotifierOptions{})
 
 	s.Cancel()
 	if s.num != 0 {
@@ -101,3 +159,300 @@ func TestNotifierCanceld(t *testing.T) {
 		t.Fatalf("notifier: expected zero id, have %d", n.id)
 	}
 }
+
+func TestNotifierOverflow(t *testing.T) {
+	key := []byte("k")
+	for _, test := range []struct {
+		policy    OverflowPolicy
+		want      []int
+		err       error
+		dropped   uint64
+		coalesced uint64
+	}{
+		{DropOldest, []int{1, 5, 6}, NotifierCanceled, 3, 0},
+		{Coalesce, []int{1, 6}, NotifierCanceled, 0, 4},
+		{CancelSlow, []int{1, 2, 3}, ErrSlowConsumer, 1, 0},
+	} {
+		db := New()
+		tx := db.Txn()
+		tx.Put(key, 0, false)
+		tx.Commit()
+
+		n, _, err := db.WatchWith(key, NotifierOptions{Capacity: 2, Policy: test.policy})
+		if err != nil {
+			t.Fatalf("create notifier: %v", err)
+		}
+		for i := 1; i <= 6; i++ {
+			tx = db.Txn()
+			tx.Put(key, i, false)
+			tx.Commit()
+			if i == 1 { // wait until the first event is in flight
+				waitPending(t, n, 0)
+			}
+		}
+
+		stats := n.Stats()
+		if stats.Dropped != test.dropped || stats.Coalesced != test.coalesced {
+			t.Fatalf("overflow %d: expected %d dropped and %d coalesced, have %+v",
+				test.policy, test.dropped, test.coalesced, stats)
+		}
+		n.Cancel()
+
+		have := []int{}
+		for ev := range n.Recv() {
+			if ev.Err() != nil {
+				if ev.Err() != test.err {
+					t.Fatalf("overflow %d: expected error %v, have %v", test.policy, test.err, ev.Err())
+				}
+				continue
+			}
+			have = append(have, ev.Data.(int))
+		}
+		if len(have) != len(test.want) {
+			t.Fatalf("overflow %d: expected %v, have %v", test.policy, test.want, have)
+		}
+		for i := range have {
+			if have[i] != test.want[i] {
+				t.Fatalf("overflow %d: expected %v, have %v", test.policy, test.want, have)
+			}
+		}
+	}
+}
+
+func TestNotifierBlock(t *testing.T) {
+	key := []byte("k")
+	db := New()
+	tx := db.Txn()
+	tx.Put(key, 0, false)
+	tx.Commit()
+
+	n, _, err := db.WatchWith(key, NotifierOptions{Capacity: 1, Policy: Block})
+	if err != nil {
+		t.Fatalf("create notifier: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i <= 3; i++ {
+			tx := db.Txn()
+			tx.Put(key, i, false)
+			tx.Commit()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("notifier: expected blocked writer")
+	case <-time.After(50 * time.Millisecond):
+	}
+	n.Cancel() // unblocks the writer
+	<-done
+	for range n.Recv() {
+	}
+	if db.Rev() != 4 {
+		t.Fatalf("notifier: expected revision 4, have %d", db.Rev())
+	}
+}
+
+func TestNotifierDefaultPolicy(t *testing.T) {
+	key := []byte("k")
+	db := New()
+	tx := db.Txn()
+	tx.Put(key, 0, false)
+	tx.Commit()
+
+	n, _, err := db.WatchWith(key, NotifierOptions{Capacity: 1})
+	if err != nil {
+		t.Fatalf("create notifier: %v", err)
+	}
+	defer n.Cancel()
+
+	done := make(chan struct{})
+	go func() { // the notifier is never read
+		for i := 1; i <= 5; i++ {
+			tx := db.Txn()
+			tx.Put(key, i, false)
+			tx.Commit()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("notifier: writer blocked by a stalled consumer")
+	}
+	if db.Rev() != 6 {
+		t.Fatalf("notifier: expected revision 6, have %d", db.Rev())
+	}
+	if stats := n.Stats(); stats.Dropped == 0 {
+		t.Fatalf("notifier: expected dropped events, have %+v", stats)
+	}
+}
+
+func waitPending(t *testing.T, n *Notifier, pending int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for n.Stats().Pending != pending {
+		if time.Now().After(deadline) {
+			t.Fatalf("notifier: expected %d pending events, have %d", pending, n.Stats().Pending)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchContext(t *testing.T) {
+	key := []byte("k")
+	db := New()
+	tx := db.Txn()
+	tx.Put(key, 0, false)
+	tx.Commit()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n, _, err := db.WatchContext(ctx, key, NotifierOptions{})
+	if err != nil {
+		t.Fatalf("create notifier: %v", err)
+	}
+	tx = db.Txn()
+	tx.Put(key, 1, false)
+	tx.Commit()
+
+	ev, err := n.Next(context.Background())
+	if err != nil || ev.Data.(int) != 1 {
+		t.Fatalf("next: expected value 1, have %v (%v)", ev.Data, err)
+	}
+	timeout, stop := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer stop()
+	if _, err = n.Next(timeout); err != context.DeadlineExceeded {
+		t.Fatalf("next: expected error %v, have %v", context.DeadlineExceeded, err)
+	}
+
+	cancel()
+	for ev = range n.Recv() { // the channel is closed
+	}
+	if _, err = n.Next(context.Background()); err != NotifierCanceled {
+		t.Fatalf("next: expected error %v, have %v", NotifierCanceled, err)
+	}
+
+	p := db.load().root.Get(newMatcher(key)).(*pair)
+	p.stream.mu.Lock()
+	running := p.stream.running
+	p.stream.mu.Unlock()
+	if running {
+		t.Fatalf("stream: expected canceled notifier to be unregistered")
+	}
+}
+
+func TestRangeContext(t *testing.T) {
+	db := New()
+	tx := db.Txn()
+	for i := 0; i < 4*defaultNotifierCapacity; i++ {
+		tx.Put([]byte(fmt.Sprintf("k%04d", i)), i, false)
+	}
+	tx.Commit()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n, _, err := db.RangeContext(ctx, nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("range: %v", err)
+	}
+	if ev, err := n.Next(ctx); err != nil || ev.Data.(int) != 0 {
+		t.Fatalf("range: expected value 0, have %v (%v)", ev.Data, err)
+	}
+
+	cancel() // stops the blocked range iteration
+	count := 0
+	for range n.Recv() {
+		count++
+	}
+	if count > defaultNotifierCapacity+1 {
+		t.Fatalf("range: expected at most %d pending events, have %d",
+			defaultNotifierCapacity+1, count)
+	}
+}
+
+func TestSingleNotifier(t *testing.T) {
+	key := []byte("k")
+	db := New()
+	tx := db.Txn()
+	tx.Put(key, 1, false)
+	tx.Commit()
+
+	for _, test := range []struct {
+		key []byte
+		err error
+	}{
+		{key, nil},
+		{[]byte("missing"), ErrKeyNotFound},
+	} {
+		n, _, err := db.Range(test.key, nil, 0, 0)
+		if err != nil {
+			t.Fatalf("range: %v", err)
+		}
+		count := 0
+		for ev := range n.Recv() { // terminates after the result
+			if ev.Err() != test.err {
+				t.Fatalf("single: expected error %v, have %v", test.err, ev.Err())
+			}
+			count++
+		}
+		if count != 1 {
+			t.Fatalf("single: expected 1 event, have %d", count)
+		}
+		if _, err := n.Next(context.Background()); err != NotifierCanceled {
+			t.Fatalf("single: expected error %v, have %v", NotifierCanceled, err)
+		}
+		n.Cancel() // no-op
+	}
+}
+
+func TestPersistentNotifier(t *testing.T) {
+	key := []byte("k")
+	db := New()
+	n, _, err := db.WatchWith(key, NotifierOptions{Persistent: true})
+	if err != nil {
+		t.Fatalf("create notifier: %v", err)
+	}
+
+	tx := db.Txn()
+	tx.Put(key, 1, false)
+	tx.Commit()
+	w, _, err := db.Watch(key)
+	if err != nil {
+		t.Fatalf("create notifier: %v", err)
+	}
+	tx = db.Txn()
+	tx.Delete(key)
+	tx.Commit()
+	tx = db.Txn()
+	tx.Put(key, 2, false)
+	tx.Put([]byte("other"), 3, false)
+	tx.Commit()
+
+	<-w.Recv() // the deletion notifies the last value
+	if ev := <-w.Recv(); ev.Err() != PairDeleted {
+		t.Fatalf("notifier: expected error %v, have %v", PairDeleted, ev.Err())
+	}
+
+	for i, want := range []Event{
+		{Data: 1, Created: 1, Current: 1, Key: key},
+		{Current: 2, Key: key, Deleted: true},
+		{Data: 2, Created: 3, Current: 3, Key: key},
+	} {
+		ev, err := n.Next(context.Background())
+		if err != nil {
+			t.Fatalf("persistent #%d: %v", i, err)
+		}
+		if ev.Data != want.Data || ev.Created != want.Created || ev.Current != want.Current ||
+			ev.Deleted != want.Deleted || string(ev.Key) != string(want.Key) {
+			t.Fatalf("persistent #%d: expected %+v, have %+v", i, want, ev)
+		}
+	}
+
+	n.Cancel()
+	db.keys.mu.Lock()
+	streams := len(db.keys.streams)
+	db.keys.mu.Unlock()
+	if streams != 0 {
+		t.Fatalf("persistent: expected no key streams, have %d", streams)
+	}
+}
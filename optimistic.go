@@ -0,0 +1,245 @@
+package db
+
+// ErrConflict is returned when an optimistic transaction cannot be
+// committed because a key it has read or written has been changed
+// since the transaction started. The transaction can be retried.
+const ErrConflict = perror("transaction conflict")
+
+// OptimisticTxn represents a transaction which does not block other
+// writers. Reads are performed on the revision the transaction started
+// at and updates are buffered until commit. Any number of optimistic
+// transactions can run in parallel.
+//
+// On commit the keys read or written by the transaction are validated
+// against the changes committed since the transaction started. If none
+// of them has changed, the buffered updates are applied on top of the
+// current revision of the database, otherwise the transaction fails
+// with ErrConflict.
+type OptimisticTxn struct {
+	db     *DB
+	tree   *tree
+	reads  map[string]struct{}
+	writes []write
+	last   map[string]int // index of the last write of a key
+}
+
+type write struct {
+	key       []byte
+	data      interface{}
+	tombstone bool
+	deleted   bool
+	merge     string // merge operator name, data is the operand
+}
+
+// OptimisticTxn starts a new optimistic transaction at the current
+// revision of the database.
+func (db *DB) OptimisticTxn() *OptimisticTxn {
+	return &OptimisticTxn{
+		db:    db,
+		tree:  db.load(),
+		reads: make(map[string]struct{}),
+		last:  make(map[string]int),
+	}
+}
+
+// Rev returns the revision the transaction has been started at.
+func (tx *OptimisticTxn) Rev() int64 { return tx.tree.rev }
+
+// Get returns the value for a key within the transaction, including
+// buffered updates, and the revision of the value. Buffered updates
+// report the revision the transaction has been started at.
+func (tx *OptimisticTxn) Get(key []byte) (interface{}, int64, error) {
+	if i, found := tx.last[string(key)]; found {
+		w := tx.writes[i]
+		if w.merge != "" {
+			return tx.fold(key)
+		}
+		if w.deleted {
+			return nil, 0, ErrKeyNotFound
+		}
+		return w.data, tx.tree.rev, nil
+	}
+
+	tx.reads[string(key)] = struct{}{}
+	if p := lookupPair(tx.tree, key); p != nil {
+		b := p.last()
+		return b.Data, b.Rev, nil
+	}
+	return nil, 0, ErrKeyNotFound
+}
+
+// fold returns the value of a key with buffered merges applied to the
+// last buffered update or, if there is none, to the value the
+// transaction has been started at.
+func (tx *OptimisticTxn) fold(key []byte) (interface{}, int64, error) {
+	var data interface{}
+	start := -1 // last buffered update which is not a merge
+	for i, w := range tx.writes {
+		if compare(w.key, key) == 0 && w.merge == "" {
+			start = i
+		}
+	}
+	if start >= 0 {
+		data = tx.writes[start].data // nil for deletes
+	} else {
+		tx.reads[string(key)] = struct{}{}
+		if p := lookupPair(tx.tree, key); p != nil {
+			data = p.last().Data
+		}
+	}
+
+	var err error
+	for _, w := range tx.writes[start+1:] {
+		if compare(w.key, key) != 0 {
+			continue
+		}
+		if data, err = tx.db.merge(w.merge, key, data, w.data); err != nil {
+			return nil, 0, err
+		}
+	}
+	return data, tx.tree.rev, nil
+}
+
+// Update buffers an update of the value for a key. The key counts as
+// read by the transaction. It the key exists and the value data type
+// differ it returns an error.
+func (tx *OptimisticTxn) Update(key []byte, up Updater, tombstone bool) error {
+	if tx.db.readOnly {
+		return ErrReadOnly
+	}
+	last, _, err := tx.Get(key)
+	if err != nil && err != ErrKeyNotFound {
+		return err
+	}
+	data := up(last)
+	if err == nil && !typeEqual(last, data) {
+		return ErrIncompatibleValue
+	}
+	tx.buffer(write{key: key, data: data, tombstone: tombstone})
+	return nil
+}
+
+// Put buffers setting the value for a key. Supplied key and value must
+// remain valid for the life of the database.
+func (tx *OptimisticTxn) Put(key []byte, data interface{}, tombstone bool) error {
+	return tx.Update(key, noop(data), tombstone)
+}
+
+// Delete buffers the removal of a key/value pair.
+func (tx *OptimisticTxn) Delete(key []byte) error {
+	if tx.db.readOnly {
+		return ErrReadOnly
+	}
+	tx.buffer(write{key: key, deleted: true})
+	return nil
+}
+
+// Merge buffers merging operand into the value of a key using the
+// merge operator registered under name. Unlike Update, Merge does not
+// read the key: the operand is merged into the latest value on commit,
+// so that concurrent merges of a key do not conflict.
+func (tx *OptimisticTxn) Merge(key []byte, name string, operand interface{}) error {
+	if tx.db.readOnly {
+		return ErrReadOnly
+	}
+	if _, err := tx.db.mergeOperator(name); err != nil {
+		return err
+	}
+	tx.buffer(write{key: key, data: operand, merge: name})
+	return nil
+}
+
+func (tx *OptimisticTxn) buffer(w write) {
+	tx.last[string(w.key)] = len(tx.writes)
+	tx.writes = append(tx.writes, w)
+}
+
+// Commit validates the transaction and applies the buffered updates.
+// It returns the current revision of the database and ErrConflict if
+// a key read or written by the transaction has been changed since the
+// transaction started.
+func (tx *OptimisticTxn) Commit() (int64, error) {
+	if len(tx.writes) == 0 { // reads of a single revision are consistent
+		return tx.tree.rev, nil
+	}
+
+	txn := tx.db.Txn()
+	if txn.readOnly {
+		txn.Rollback()
+		return txn.rev, ErrReadOnly
+	}
+	current := tx.db.load()
+	if tx.conflicts(current) {
+		txn.Rollback()
+		return current.rev, ErrConflict
+	}
+
+	for _, w := range tx.writes {
+		var err error
+		switch {
+		case w.merge != "":
+			_, err = txn.Merge(w.key, w.merge, w.data)
+		case w.deleted:
+			txn.Delete(w.key)
+		default:
+			_, err = txn.Put(w.key, w.data, w.tombstone)
+		}
+		if err != nil {
+			txn.Rollback()
+			return current.rev, err
+		}
+	}
+	rev := txn.rev
+	if err := txn.Commit(); err != nil {
+		return current.rev, err
+	}
+	tx.Rollback()
+	return rev, nil
+}
+
+// conflicts reports whether a key read or written by the transaction
+// has been changed in current.
+func (tx *OptimisticTxn) conflicts(current *tree) bool {
+	if current.rev == tx.tree.rev {
+		return false
+	}
+	changed := func(key []byte) bool {
+		return lastRev(tx.tree, key) != lastRev(current, key)
+	}
+	for key := range tx.reads {
+		if changed([]byte(key)) {
+			return true
+		}
+	}
+	for _, w := range tx.writes {
+		if w.merge == "" && changed(w.key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rollback discards all buffered updates.
+func (tx *OptimisticTxn) Rollback() {
+	tx.reads = make(map[string]struct{})
+	tx.writes = nil
+	tx.last = make(map[string]int)
+}
+
+func lookupPair(t *tree, key []byte) *pair {
+	match := newMatcher(key)
+	defer match.release()
+	if elem := t.root.Get(match); elem != nil {
+		return elem.(*pair)
+	}
+	return nil
+}
+
+// lastRev returns the revision of the last update of a key or zero if
+// the key does not exist.
+func lastRev(t *tree, key []byte) int64 {
+	if p := lookupPair(t, key); p != nil {
+		return p.last().Rev
+	}
+	return 0
+}
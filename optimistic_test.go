@@ -0,0 +1,112 @@
+package db
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestOptimisticTxn(t *testing.T) {
+	db := New()
+	tx := db.Txn()
+	tx.Put([]byte("a"), 1, false)
+	tx.Put([]byte("b"), 2, false)
+	tx.Commit()
+
+	t1, t2 := db.OptimisticTxn(), db.OptimisticTxn()
+	if data, rev, err := t1.Get([]byte("a")); err != nil || data.(int) != 1 || rev != 1 {
+		t.Fatalf("optimistic: expected 1 at revision 1, have %v at %d (%v)", data, rev, err)
+	}
+	t1.Put([]byte("c"), 3, false)
+	t1.Delete([]byte("b"))
+	if _, _, err := t1.Get([]byte("b")); err != ErrKeyNotFound {
+		t.Fatalf("optimistic: expected error %v, have %v", ErrKeyNotFound, err)
+	}
+	if err := t1.Put([]byte("a"), "x", false); err != ErrIncompatibleValue {
+		t.Fatalf("optimistic: expected error %v, have %v", ErrIncompatibleValue, err)
+	}
+	t2.Put([]byte("d"), 4, false)
+
+	if rev, err := t2.Commit(); err != nil || rev != 3 {
+		t.Fatalf("optimistic: expected revision 3, have %d (%v)", rev, err)
+	}
+	if rev, err := t1.Commit(); err != nil || rev != 5 {
+		t.Fatalf("optimistic: expected revision 5, have %d (%v)", rev, err)
+	}
+	if _, _, _, err := db.Get([]byte("b"), 0, false); err != ErrKeyNotFound {
+		t.Fatalf("optimistic: expected error %v, have %v", ErrKeyNotFound, err)
+	}
+}
+
+func TestOptimisticConflict(t *testing.T) {
+	for i, test := range []struct {
+		txn   func(tx *OptimisticTxn)
+		other func(tx *Txn)
+	}{
+		{ // read and update
+			func(tx *OptimisticTxn) { tx.Get([]byte("a")); tx.Put([]byte("b"), 1, false) },
+			func(tx *Txn) { tx.Put([]byte("a"), 2, false) },
+		},
+		{ // blind write
+			func(tx *OptimisticTxn) { tx.Put([]byte("a"), 1, false) },
+			func(tx *Txn) { tx.Put([]byte("a"), 2, false) },
+		},
+		{ // missing key created
+			func(tx *OptimisticTxn) { tx.Get([]byte("x")); tx.Put([]byte("b"), 1, false) },
+			func(tx *Txn) { tx.Put([]byte("x"), 2, false) },
+		},
+		{ // delete and re-create
+			func(tx *OptimisticTxn) { tx.Delete([]byte("a")) },
+			func(tx *Txn) { tx.Delete([]byte("a")); tx.Put([]byte("a"), 0, false) },
+		},
+	} {
+		db := New()
+		tx := db.Txn()
+		tx.Put([]byte("a"), 0, false)
+		tx.Commit()
+
+		otx := db.OptimisticTxn()
+		test.txn(otx)
+		tx = db.Txn()
+		test.other(tx)
+		tx.Commit()
+
+		rev := db.Rev()
+		if _, err := otx.Commit(); err != ErrConflict {
+			t.Fatalf("conflict #%d: expected error %v, have %v", i, ErrConflict, err)
+		}
+		if db.Rev() != rev {
+			t.Fatalf("conflict #%d: expected revision %d, have %d", i, rev, db.Rev())
+		}
+	}
+}
+
+func TestOptimisticParallel(t *testing.T) {
+	key, workers, count := []byte("counter"), 8, 50
+	db := New()
+	tx := db.Txn()
+	tx.Put(key, 0, false)
+	tx.Commit()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < count; {
+				tx := db.OptimisticTxn()
+				tx.Update(key, func(data interface{}) interface{} { return data.(int) + 1 }, false)
+				if _, err := tx.Commit(); err == nil {
+					n++
+				} else if err != ErrConflict {
+					t.Errorf("optimistic: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if data, _, _, _ := db.Get(key, 0, false); data.(int) != workers*count {
+		t.Fatalf("optimistic: expected counter %d, have %v", workers*count, data)
+	}
+}
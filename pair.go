@@ -16,6 +16,7 @@ type pair struct {
 	blocks []block
 	key    []byte
 	stream *stream
+	max    bool // compares greater than all keys, see maxMatcher
 }
 
 // block represents an immutable data revision. This structure must be
@@ -54,9 +55,22 @@ func (p *pair) release() {
 	matcherPool.Put(p)
 }
 
+// maxMatcher is the upper bound of ranges without an end key.
+var maxMatcher = &pair{max: true}
+
 // Compare implements the llrb.Element interface.
 func (p pair) Compare(elem llrb.Element) int {
-	return bytes.Compare(p.key, elem.(*pair).key)
+	q := elem.(*pair)
+	if p.max || q.max {
+		switch {
+		case p.max == q.max:
+			return 0
+		case p.max:
+			return 1
+		}
+		return -1
+	}
+	return bytes.Compare(p.key, q.key)
 }
 
 // find returns the index at which rev is greater or equal, if all
@@ -83,12 +97,15 @@ func (p pair) find(rev int64, equal bool) (int, bool) {
 
 // inserts sets the data for a key in the pair. If ts is true insert
 // sets a new tombstone pair and all previous revisions will be
-// deleted.
+// deleted. If the last block has been created at rev, it is replaced.
 func (p pair) insert(data interface{}, rev int64, ts bool) *pair {
 	if !ts {
 		n := len(p.blocks)
+		if p.last().Rev == rev {
+			n--
+		}
 		blocks := make([]block, n+1)
-		copy(blocks, p.blocks)
+		copy(blocks, p.blocks[:n])
 		blocks[n] = block{Data: data, Rev: rev}
 
 		return &pair{blocks: blocks, key: p.key, stream: p.stream}
@@ -0,0 +1,1003 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/azmodb/db/backend"
+)
+
+const (
+	// ErrNotLeader is returned when proposing a transaction on a node
+	// which is not the leader of the cluster.
+	ErrNotLeader = perror("node is not the leader")
+
+	// ErrProposalDropped is returned when a proposed transaction has
+	// not been committed, because the leader changed or the state of
+	// the database changed in the meantime. The proposal can be
+	// retried.
+	ErrProposalDropped = perror("proposal dropped")
+
+	// ErrNodeStopped is returned when the node has been stopped.
+	ErrNodeStopped = perror("node is stopped")
+)
+
+// RaftMessageType represents the type of a Raft message.
+type RaftMessageType int
+
+// Raft message types.
+const (
+	MsgVote RaftMessageType = iota + 1
+	MsgVoteResp
+	MsgAppend
+	MsgAppendResp
+	MsgSnapshot
+)
+
+// RaftMessage represents a message exchanged between the nodes of a
+// Raft cluster.
+type RaftMessage struct {
+	Type RaftMessageType
+	From uint64
+	To   uint64
+	Term uint64
+
+	// Incarnation identifies the run of the sending node. Messages of
+	// a restarted node reusing an ID are rejected by its peers.
+	Incarnation uint64
+
+	// MsgVote
+	LastIndex uint64
+	LastTerm  uint64
+
+	// MsgVoteResp, MsgAppendResp
+	Reject bool
+
+	// MsgAppend
+	PrevIndex uint64
+	PrevTerm  uint64
+	Entries   []raftEntry
+	Commit    uint64
+
+	// MsgAppendResp, index of the last matching log entry
+	Match uint64
+
+	// MsgSnapshot
+	Snapshot raftSnapshot
+}
+
+// raftEntry represents a Raft log entry. Entries without changes are
+// no-op entries appended by a new leader.
+type raftEntry struct {
+	Term   uint64
+	Index  uint64
+	Record record
+}
+
+// raftSnapshot represents a database snapshot replacing all log
+// entries up to Index.
+type raftSnapshot struct {
+	Index uint64
+	Term  uint64
+	Rev   int64
+	Pairs []kv
+}
+
+type kv struct {
+	Key   []byte
+	Value []byte
+}
+
+// Transport delivers Raft messages between the nodes of a cluster.
+// Send must not block, messages may be dropped.
+type Transport interface {
+	Send(msg RaftMessage)
+}
+
+// LocalTransport is an in-process transport connecting nodes of a
+// cluster running in the same process.
+type LocalTransport struct {
+	mu    sync.Mutex
+	nodes map[uint64]*Node
+	down  map[uint64]bool
+}
+
+// NewLocalTransport returns an in-process transport.
+func NewLocalTransport() *LocalTransport {
+	return &LocalTransport{
+		nodes: make(map[uint64]*Node),
+		down:  make(map[uint64]bool),
+	}
+}
+
+// Add connects a node to the transport.
+func (t *LocalTransport) Add(n *Node) {
+	t.mu.Lock()
+	t.nodes[n.id] = n
+	t.mu.Unlock()
+}
+
+// Disconnect drops all messages sent from or to the node with the
+// given id.
+func (t *LocalTransport) Disconnect(id uint64) {
+	t.mu.Lock()
+	t.down[id] = true
+	t.mu.Unlock()
+}
+
+// Connect reconnects a previously disconnected node.
+func (t *LocalTransport) Connect(id uint64) {
+	t.mu.Lock()
+	delete(t.down, id)
+	t.mu.Unlock()
+}
+
+// Send implements the Transport interface.
+func (t *LocalTransport) Send(msg RaftMessage) {
+	t.mu.Lock()
+	n, found := t.nodes[msg.To]
+	if !found || t.down[msg.From] || t.down[msg.To] {
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+	n.Step(msg)
+}
+
+// RaftConfig configures a Raft node.
+type RaftConfig struct {
+	// ID is the identifier of the node, it must be greater than zero.
+	//
+	// The term, vote and log of a node are kept in memory only. A
+	// restarted node has lost its vote and could vote twice in a term,
+	// so an ID must never be reused after a node has been stopped.
+	// Peers ignore all messages of a node reusing an ID.
+	ID uint64
+
+	// Peers are the identifiers of all nodes in the cluster, including
+	// the node itself.
+	Peers []uint64
+
+	// Transport delivers messages to the other nodes.
+	Transport Transport
+
+	// TickInterval is the duration of a logical clock tick. If zero, a
+	// default of 10 milliseconds is used.
+	TickInterval time.Duration
+
+	// ElectionTicks is the number of ticks without a message from the
+	// leader after which a follower starts an election. If zero, a
+	// default of 10 ticks is used.
+	ElectionTicks int
+
+	// HeartbeatTicks is the number of ticks between two heartbeats of
+	// the leader. If zero, a default of 1 tick is used.
+	HeartbeatTicks int
+
+	// SnapshotEntries is the number of applied log entries after which
+	// the log is compacted into a snapshot. If zero, a default of 1024
+	// entries is used.
+	SnapshotEntries int
+}
+
+const (
+	defaultTickInterval    = 10 * time.Millisecond
+	defaultElectionTicks   = 10
+	defaultHeartbeatTicks  = 1
+	defaultSnapshotEntries = 1024
+)
+
+type raftState int
+
+const (
+	follower raftState = iota
+	candidate
+	leader
+)
+
+// Node represents a node of a Raft replicated database. Transactions
+// are proposed to the leader, replicated to a majority of the nodes
+// and applied to every database replica in revision order.
+type Node struct {
+	id          uint64
+	incarnation uint64
+	peers       []uint64
+	transport   Transport
+	cfg         RaftConfig
+
+	db      *DB        // read-only replica
+	propose sync.Mutex // serializes proposals
+	inbox   chan RaftMessage
+	applyc  chan struct{} // signals the applier
+	stop    chan struct{}
+	wg      sync.WaitGroup
+
+	mu           sync.Mutex
+	applied      *sync.Cond // signaled when entries are applied
+	state        raftState
+	term         uint64
+	vote         uint64
+	lead         uint64
+	votes        map[uint64]bool
+	log          []raftEntry // log[0] is the last compacted entry
+	commit       uint64
+	last         uint64 // last applied log index
+	snapshot     raftSnapshot
+	restoring    *restore // received snapshot not yet applied
+	next         map[uint64]uint64
+	match        map[uint64]uint64
+	inflight     map[uint64]inflight // snapshots sent to lagging followers
+	incarnations map[uint64]uint64   // incarnations of the peers
+	pending      map[uint64]*proposal
+	elapsed      int
+	timeout      int
+	stopped      bool
+}
+
+type proposal struct {
+	term uint64
+	rev  int64
+	err  error
+	done chan struct{}
+}
+
+type restore struct {
+	index uint64
+	tree  *tree
+}
+
+// inflight tracks a snapshot sent to a follower until the follower
+// acknowledges it.
+type inflight struct {
+	index uint64
+	ticks int
+}
+
+// NewNode starts a Raft node replicating into a new, empty database.
+func NewNode(cfg RaftConfig) *Node {
+	if cfg.ID == 0 {
+		panic("raft: cannot use id 0")
+	}
+	if cfg.TickInterval <= 0 {
+		cfg.TickInterval = defaultTickInterval
+	}
+	if cfg.ElectionTicks <= 0 {
+		cfg.ElectionTicks = defaultElectionTicks
+	}
+	if cfg.HeartbeatTicks <= 0 {
+		cfg.HeartbeatTicks = defaultHeartbeatTicks
+	}
+	if cfg.SnapshotEntries <= 0 {
+		cfg.SnapshotEntries = defaultSnapshotEntries
+	}
+
+	db := New()
+	db.readOnly = true
+	n := &Node{
+		id:           cfg.ID,
+		incarnation:  rand.Uint64() | 1,
+		peers:        cfg.Peers,
+		transport:    cfg.Transport,
+		cfg:          cfg,
+		db:           db,
+		inbox:        make(chan RaftMessage, 1024),
+		applyc:       make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		log:          []raftEntry{raftEntry{}},
+		pending:      make(map[uint64]*proposal),
+		incarnations: make(map[uint64]uint64),
+	}
+	n.applied = sync.NewCond(&n.mu)
+	n.becomeFollower(0, 0)
+
+	n.wg.Add(2)
+	go n.run()
+	go n.applier()
+	return n
+}
+
+// DB returns the read-only database replica of the node.
+func (n *Node) DB() *DB { return n.db }
+
+// ID returns the identifier of the node.
+func (n *Node) ID() uint64 { return n.id }
+
+// Leader returns the identifier of the current leader or zero if the
+// leader is unknown.
+func (n *Node) Leader() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.lead
+}
+
+// Step delivers a message to the node. Step does not block, the message
+// is dropped if the node is overloaded.
+func (n *Node) Step(msg RaftMessage) {
+	select {
+	case n.inbox <- msg:
+	default:
+	}
+}
+
+// Stop stops the node. Pending proposals return ErrNodeStopped.
+func (n *Node) Stop() {
+	n.mu.Lock()
+	if n.stopped {
+		n.mu.Unlock()
+		return
+	}
+	n.stopped = true
+	for index, p := range n.pending {
+		p.err = ErrNodeStopped
+		close(p.done)
+		delete(n.pending, index)
+	}
+	n.applied.Broadcast()
+	n.mu.Unlock()
+
+	close(n.stop)
+	n.wg.Wait()
+}
+
+// Update proposes the changes made by fn. It runs fn within a
+// transaction on the current state of the leader, replicates the
+// changes and waits until they have been applied to the local replica.
+// Fn must not commit or rollback the transaction. If fn returns an
+// error or a validator of the leader rejects the changes, nothing is
+// proposed.
+//
+// Update returns the revision of the database after the changes have
+// been applied and an error if any.
+func (n *Node) Update(ctx context.Context, fn func(tx *Txn) error) (int64, error) {
+	n.propose.Lock()
+	defer n.propose.Unlock()
+
+	// Wait until all entries of the log have been applied, so that fn
+	// operates on the latest state of the database.
+	n.mu.Lock()
+	for {
+		if n.stopped {
+			n.mu.Unlock()
+			return 0, ErrNodeStopped
+		}
+		if n.state != leader {
+			n.mu.Unlock()
+			return 0, ErrNotLeader
+		}
+		if err := ctx.Err(); err != nil {
+			n.mu.Unlock()
+			return 0, err
+		}
+		if n.last == n.lastIndex() {
+			break
+		}
+		n.applied.Wait()
+	}
+	n.mu.Unlock()
+
+	rec, rev, err := n.prepare(fn)
+	if err != nil {
+		return rev, err
+	}
+	if len(rec.Changes) == 0 {
+		return rev, nil
+	}
+
+	n.mu.Lock()
+	if n.state != leader {
+		n.mu.Unlock()
+		return 0, ErrNotLeader
+	}
+	index := n.lastIndex() + 1
+	p := &proposal{term: n.term, done: make(chan struct{})}
+	n.pending[index] = p
+	n.log = append(n.log, raftEntry{Term: n.term, Index: index, Record: rec})
+	n.match[n.id] = index
+	n.broadcastAppend()
+	n.maybeCommit()
+	n.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return p.rev, p.err
+	case <-ctx.Done():
+		n.mu.Lock()
+		if n.pending[index] == p {
+			delete(n.pending, index)
+		}
+		n.mu.Unlock()
+		return 0, ctx.Err()
+	}
+}
+
+// prepare runs fn within a transaction which is always rolled back and
+// returns the changes made by fn.
+func (n *Node) prepare(fn func(tx *Txn) error) (rec record, rev int64, err error) {
+	tx := n.db.txn()
+	tx.managed = true
+	defer tx.rollback()
+
+	if err = fn(tx); err != nil {
+		return rec, tx.rev, err
+	}
+	if len(tx.changes) > 0 {
+		rec.From, rec.To = tx.changes[0].Rev, tx.rev
+		tree := &tree{root: tx.txn.Commit(), rev: tx.rev, len: tx.len}
+		if err = tx.validate(tree, record{From: rec.From, To: rec.To, Changes: tx.changes}); err != nil {
+			return record{}, tx.rev, err
+		}
+		rec.Changes = make([]change, len(tx.changes))
+		for i, c := range tx.changes {
+			c.pair = nil
+			rec.Changes[i] = c
+		}
+	}
+	return rec, tx.rev, nil
+}
+
+func (n *Node) run() {
+	ticker := time.NewTicker(n.cfg.TickInterval)
+	defer func() {
+		ticker.Stop()
+		n.wg.Done()
+	}()
+
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-ticker.C:
+			n.mu.Lock()
+			n.tick()
+			n.applied.Broadcast() // wake up waiters to check their context
+			n.mu.Unlock()
+		case msg := <-n.inbox:
+			n.mu.Lock()
+			n.step(msg)
+			n.mu.Unlock()
+		}
+	}
+}
+
+func (n *Node) lastIndex() uint64 { return n.log[len(n.log)-1].Index }
+
+func (n *Node) lastTerm() uint64 { return n.log[len(n.log)-1].Term }
+
+func (n *Node) offset() uint64 { return n.log[0].Index }
+
+// termAt returns the term of the entry at index and false if the entry
+// has been compacted or does not exist.
+func (n *Node) termAt(index uint64) (uint64, bool) {
+	if index < n.offset() || index > n.lastIndex() {
+		return 0, false
+	}
+	return n.log[index-n.offset()].Term, true
+}
+
+func (n *Node) quorum() int { return len(n.peers)/2 + 1 }
+
+func (n *Node) resetTimeout() {
+	n.elapsed = 0
+	n.timeout = n.cfg.ElectionTicks + rand.Intn(n.cfg.ElectionTicks)
+}
+
+func (n *Node) send(msg RaftMessage) {
+	msg.From = n.id
+	msg.Incarnation = n.incarnation
+	if msg.Term == 0 {
+		msg.Term = n.term
+	}
+	n.transport.Send(msg)
+}
+
+func (n *Node) becomeFollower(term, lead uint64) {
+	if term > n.term {
+		n.term = term
+		n.vote = 0
+	}
+	n.state = follower
+	n.lead = lead
+	n.resetTimeout()
+}
+
+func (n *Node) becomeCandidate() {
+	n.state = candidate
+	n.term++
+	n.vote = n.id
+	n.lead = 0
+	n.votes = map[uint64]bool{n.id: true}
+	n.resetTimeout()
+
+	if len(n.votes) >= n.quorum() {
+		n.becomeLeader()
+		return
+	}
+	for _, id := range n.peers {
+		if id == n.id {
+			continue
+		}
+		n.send(RaftMessage{
+			Type:      MsgVote,
+			To:        id,
+			LastIndex: n.lastIndex(),
+			LastTerm:  n.lastTerm(),
+		})
+	}
+}
+
+func (n *Node) becomeLeader() {
+	n.state = leader
+	n.lead = n.id
+	n.next = make(map[uint64]uint64)
+	n.match = make(map[uint64]uint64)
+	n.inflight = make(map[uint64]inflight)
+	for _, id := range n.peers {
+		n.next[id] = n.lastIndex() + 1
+		n.match[id] = 0
+	}
+
+	// Append a no-op entry to commit entries of previous terms.
+	index := n.lastIndex() + 1
+	n.log = append(n.log, raftEntry{Term: n.term, Index: index})
+	n.match[n.id] = index
+	n.broadcastAppend()
+	n.maybeCommit()
+}
+
+func (n *Node) tick() {
+	if n.state == leader {
+		for id, s := range n.inflight {
+			s.ticks++
+			n.inflight[id] = s
+		}
+		n.elapsed++
+		if n.elapsed >= n.cfg.HeartbeatTicks {
+			n.elapsed = 0
+			n.broadcastAppend()
+		}
+		return
+	}
+
+	n.elapsed++
+	if n.elapsed >= n.timeout {
+		n.becomeCandidate()
+	}
+}
+
+func (n *Node) broadcastAppend() {
+	for _, id := range n.peers {
+		if id != n.id {
+			n.sendAppend(id)
+		}
+	}
+}
+
+func (n *Node) sendAppend(to uint64) {
+	next := n.next[to]
+	if next <= n.offset() {
+		// Send the snapshot once per lag episode and only heartbeat
+		// the follower until it acknowledges the snapshot. The
+		// snapshot is sent again if it was not acknowledged within an
+		// election timeout.
+		s, found := n.inflight[to]
+		if found && s.index == n.snapshot.Index && s.ticks < n.cfg.ElectionTicks {
+			n.send(RaftMessage{
+				Type:      MsgAppend,
+				To:        to,
+				PrevIndex: n.offset(),
+				PrevTerm:  n.log[0].Term,
+			})
+			return
+		}
+		n.inflight[to] = inflight{index: n.snapshot.Index}
+		n.send(RaftMessage{Type: MsgSnapshot, To: to, Snapshot: n.snapshot})
+		return
+	}
+
+	prev := next - 1
+	prevTerm, _ := n.termAt(prev)
+	entries := append([]raftEntry(nil), n.log[next-n.offset():]...)
+	n.send(RaftMessage{
+		Type:      MsgAppend,
+		To:        to,
+		PrevIndex: prev,
+		PrevTerm:  prevTerm,
+		Entries:   entries,
+		Commit:    n.commit,
+	})
+}
+
+func (n *Node) step(msg RaftMessage) {
+	if n.stopped {
+		return
+	}
+	if incarnation, found := n.incarnations[msg.From]; !found {
+		n.incarnations[msg.From] = msg.Incarnation
+	} else if incarnation != msg.Incarnation {
+		return // restarted node reusing an ID
+	}
+
+	switch {
+	case msg.Term > n.term:
+		lead := uint64(0)
+		if msg.Type == MsgAppend || msg.Type == MsgSnapshot {
+			lead = msg.From
+		}
+		n.becomeFollower(msg.Term, lead)
+	case msg.Term < n.term:
+		switch msg.Type { // inform stale nodes about the current term
+		case MsgVote:
+			n.send(RaftMessage{Type: MsgVoteResp, To: msg.From, Reject: true})
+		case MsgAppend, MsgSnapshot:
+			n.send(RaftMessage{Type: MsgAppendResp, To: msg.From, Reject: true})
+		}
+		return
+	}
+
+	switch msg.Type {
+	case MsgVote:
+		upToDate := msg.LastTerm > n.lastTerm() ||
+			(msg.LastTerm == n.lastTerm() && msg.LastIndex >= n.lastIndex())
+		grant := (n.vote == 0 || n.vote == msg.From) && upToDate
+		if grant {
+			n.vote = msg.From
+			n.resetTimeout()
+		}
+		n.send(RaftMessage{Type: MsgVoteResp, To: msg.From, Reject: !grant})
+
+	case MsgVoteResp:
+		if n.state != candidate || msg.Reject {
+			return
+		}
+		n.votes[msg.From] = true
+		if len(n.votes) >= n.quorum() {
+			n.becomeLeader()
+		}
+
+	case MsgAppend:
+		n.becomeFollower(msg.Term, msg.From)
+		n.handleAppend(msg)
+
+	case MsgSnapshot:
+		n.becomeFollower(msg.Term, msg.From)
+		n.handleSnapshot(msg)
+
+	case MsgAppendResp:
+		if n.state != leader {
+			return
+		}
+		s, found := n.inflight[msg.From]
+		if found && !msg.Reject && msg.Match >= s.index {
+			delete(n.inflight, msg.From)
+			found = false
+		}
+		if msg.Reject {
+			if found { // rejected heartbeat, snapshot in flight
+				return
+			}
+			next := n.next[msg.From]
+			if next > 1 {
+				next--
+			}
+			if msg.Match+1 < next {
+				next = msg.Match + 1
+			}
+			n.next[msg.From] = next
+			n.sendAppend(msg.From)
+			return
+		}
+		if msg.Match > n.match[msg.From] {
+			n.match[msg.From] = msg.Match
+		}
+		if msg.Match+1 > n.next[msg.From] {
+			n.next[msg.From] = msg.Match + 1
+		}
+		n.maybeCommit()
+	}
+}
+
+func (n *Node) handleAppend(msg RaftMessage) {
+	entries := msg.Entries
+	prev, prevTerm := msg.PrevIndex, msg.PrevTerm
+	if prev < n.offset() { // skip already compacted entries
+		skip := n.offset() - prev
+		if uint64(len(entries)) < skip {
+			entries = nil
+		} else {
+			entries = entries[skip:]
+		}
+		prev, prevTerm = n.offset(), n.log[0].Term
+	}
+
+	if term, found := n.termAt(prev); !found || term != prevTerm {
+		hint := n.lastIndex()
+		if prev <= hint {
+			hint = prev - 1
+		}
+		n.send(RaftMessage{Type: MsgAppendResp, To: msg.From, Reject: true, Match: hint})
+		return
+	}
+
+	for i, e := range entries {
+		if term, found := n.termAt(e.Index); found {
+			if term == e.Term {
+				continue
+			}
+			n.log = n.log[:e.Index-n.offset()] // conflicting entries
+		}
+		n.log = append(n.log, entries[i:]...)
+		break
+	}
+
+	match := prev + uint64(len(entries))
+	if msg.Commit > n.commit {
+		commit := msg.Commit
+		if commit > match {
+			commit = match
+		}
+		if commit > n.commit {
+			n.commit = commit
+			n.apply()
+		}
+	}
+	n.send(RaftMessage{Type: MsgAppendResp, To: msg.From, Match: match})
+}
+
+func (n *Node) handleSnapshot(msg RaftMessage) {
+	snap := msg.Snapshot
+	if snap.Index <= n.commit {
+		n.send(RaftMessage{Type: MsgAppendResp, To: msg.From, Match: n.commit})
+		return
+	}
+
+	mem := newMemBackend()
+	mem.revs[revision(snap.Rev)] = snap.Pairs
+	db, err := reload(mem)
+	if err != nil {
+		return // leader will retry
+	}
+	if term, found := n.termAt(snap.Index); found && term == snap.Term {
+		n.log = append([]raftEntry(nil), n.log[snap.Index-n.offset():]...)
+	} else {
+		n.log = []raftEntry{raftEntry{Index: snap.Index, Term: snap.Term}}
+	}
+	n.snapshot = snap
+	n.commit = snap.Index
+	n.restoring = &restore{index: snap.Index, tree: db.load()}
+	n.apply()
+	n.send(RaftMessage{Type: MsgAppendResp, To: msg.From, Match: snap.Index})
+}
+
+func (n *Node) maybeCommit() {
+	matches := make([]uint64, 0, len(n.peers))
+	for _, id := range n.peers {
+		matches = append(matches, n.match[id])
+	}
+	sort.Sort(sort.Reverse(uint64s(matches)))
+	index := matches[n.quorum()-1]
+
+	// Only entries of the current term are committed by counting
+	// replicas.
+	if term, _ := n.termAt(index); index > n.commit && term == n.term {
+		n.commit = index
+		n.apply()
+		n.broadcastAppend()
+	}
+}
+
+// apply wakes up the applier. The caller must hold n.mu.
+func (n *Node) apply() {
+	select {
+	case n.applyc <- struct{}{}:
+	default: // applier is already signaled
+	}
+}
+
+// applier applies committed entries and received snapshots to the
+// database replica. It runs without holding n.mu, so that slow
+// watchers of the replica cannot stall heartbeats and elections.
+func (n *Node) applier() {
+	defer n.wg.Done()
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-n.applyc:
+		}
+		for n.applyNext() {
+		}
+	}
+}
+
+// applyNext applies the received snapshot or the committed entries
+// and completes pending proposals. It reports false if there was
+// nothing to apply.
+func (n *Node) applyNext() bool {
+	n.mu.Lock()
+	if n.stopped {
+		n.mu.Unlock()
+		return false
+	}
+	if r := n.restoring; r != nil {
+		n.restoring = nil
+		n.mu.Unlock()
+
+		n.db.replace(r.tree)
+
+		n.mu.Lock()
+		n.last = r.index
+		n.applied.Broadcast()
+		n.mu.Unlock()
+		return true
+	}
+	if n.last >= n.commit {
+		n.mu.Unlock()
+		return false
+	}
+	entries := append([]raftEntry(nil), n.log[n.last+1-n.offset():n.commit+1-n.offset()]...)
+	n.mu.Unlock()
+
+	for _, e := range entries {
+		var err error
+		if len(e.Record.Changes) > 0 {
+			err = n.db.apply(e.Record)
+		}
+		rev := n.db.Rev()
+
+		n.mu.Lock()
+		n.last = e.Index
+		if p, found := n.pending[e.Index]; found {
+			delete(n.pending, e.Index)
+			p.rev = rev
+			p.err = err
+			if err == ErrReplicaDiverged || p.term != e.Term {
+				p.err = ErrProposalDropped
+			}
+			close(p.done)
+		}
+		n.applied.Broadcast()
+		n.mu.Unlock()
+	}
+	n.maybeCompact()
+	return true
+}
+
+// maybeCompact replaces applied log entries by a snapshot of the
+// database. It must only be called by the applier.
+func (n *Node) maybeCompact() {
+	n.mu.Lock()
+	last := n.last
+	compact := n.restoring == nil && last >= n.offset() &&
+		last-n.offset() >= uint64(n.cfg.SnapshotEntries)
+	n.mu.Unlock()
+	if !compact {
+		return
+	}
+
+	// The applier is the only writer, the database is at last.
+	mem := newMemBackend()
+	rev, err := n.db.snapshot(context.Background(), mem, SnapshotOptions{})
+	if err != nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.restoring != nil || last < n.offset() {
+		return // replaced by a received snapshot
+	}
+	term, _ := n.termAt(last)
+	n.snapshot = raftSnapshot{
+		Index: last,
+		Term:  term,
+		Rev:   rev,
+		Pairs: mem.revs[revision(rev)],
+	}
+	n.log = append([]raftEntry(nil), n.log[last-n.offset():]...)
+}
+
+func revision(rev int64) (r backend.Revision) {
+	binary.BigEndian.PutUint64(r[:], uint64(rev))
+	return r
+}
+
+type uint64s []uint64
+
+func (s uint64s) Len() int           { return len(s) }
+func (s uint64s) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint64s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// memBackend is an in-memory backend. It is used to store Raft
+// snapshots.
+type memBackend struct {
+	mu   sync.Mutex
+	revs map[backend.Revision][]kv
+}
+
+var _ backend.Backend = (*memBackend)(nil)
+
+func newMemBackend() *memBackend {
+	return &memBackend{revs: make(map[backend.Revision][]kv)}
+}
+
+func (m *memBackend) Range(rev backend.Revision, fn func(key, value []byte) error) error {
+	m.mu.Lock()
+	pairs, found := m.revs[rev]
+	m.mu.Unlock()
+	if !found {
+		return errors.New("revision not found")
+	}
+
+	for _, p := range pairs {
+		if err := fn(clone(p.Key), clone(p.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memBackend) Batch(rev backend.Revision) (backend.Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, found := m.revs[rev]; found {
+		return nil, errors.New("revision exists")
+	}
+	return &memBatch{m: m, rev: rev}, nil
+}
+
+func (m *memBackend) Last() (last backend.Revision, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for rev := range m.revs {
+		if bytes.Compare(rev[:], last[:]) > 0 {
+			last = rev
+		}
+	}
+	return last, nil
+}
+
+type memBatch struct {
+	m     *memBackend
+	rev   backend.Revision
+	pairs []kv
+}
+
+func (b *memBatch) Put(key, value []byte) error {
+	b.pairs = append(b.pairs, kv{Key: clone(key), Value: clone(value)})
+	return nil
+}
+
+func (b *memBatch) Close() error {
+	b.m.mu.Lock()
+	defer b.m.mu.Unlock()
+	if _, found := b.m.revs[b.rev]; found {
+		return errors.New("revision exists")
+	}
+
+	sort.Sort(kvs(b.pairs))
+	b.m.revs[b.rev] = b.pairs
+	return nil
+}
+
+func (b *memBatch) Rollback() error {
+	b.pairs = nil
+	return nil
+}
+
+type kvs []kv
+
+func (s kvs) Len() int           { return len(s) }
+func (s kvs) Less(i, j int) bool { return bytes.Compare(s[i].Key, s[j].Key) < 0 }
+func (s kvs) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func clone(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
@@ -0,0 +1,338 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/azmodb/db/backend"
+	"github.com/azmodb/db/backend/backendtest"
+)
+
+func TestMemBackendConformance(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) (backend.Backend, func()) {
+		return newMemBackend(), func() {}
+	})
+}
+
+func newCluster(size int, snapshotEntries int) (*LocalTransport, []*Node) {
+	transport := NewLocalTransport()
+	peers := []uint64{}
+	for i := 1; i <= size; i++ {
+		peers = append(peers, uint64(i))
+	}
+
+	nodes := []*Node{}
+	for _, id := range peers {
+		n := NewNode(RaftConfig{
+			ID:              id,
+			Peers:           peers,
+			Transport:       transport,
+			TickInterval:    time.Millisecond,
+			SnapshotEntries: snapshotEntries,
+		})
+		transport.Add(n)
+		nodes = append(nodes, n)
+	}
+	return transport, nodes
+}
+
+func stopCluster(nodes []*Node) {
+	for _, n := range nodes {
+		n.Stop()
+	}
+}
+
+// waitLeader waits until all connected nodes agree on a leader.
+func waitLeader(t *testing.T, nodes []*Node) *Node {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		lead := nodes[0].Leader()
+		agreed := lead != 0
+		for _, n := range nodes[1:] {
+			if n.Leader() != lead {
+				agreed = false
+			}
+		}
+		if agreed {
+			for _, n := range nodes {
+				if n.ID() == lead {
+					return n
+				}
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("raft: no leader elected")
+	return nil
+}
+
+// update proposes fn, retrying on leader changes.
+func update(t *testing.T, nodes []*Node, fn func(tx *Txn) error) int64 {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		n := waitLeader(t, nodes)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		rev, err := n.Update(ctx, fn)
+		cancel()
+		if err == nil {
+			return rev
+		}
+		if err != ErrNotLeader && err != ErrProposalDropped && err != context.DeadlineExceeded {
+			t.Fatalf("raft: update: %v", err)
+		}
+	}
+	t.Fatalf("raft: update timed out")
+	return 0
+}
+
+func putKey(i int, data interface{}) func(tx *Txn) error {
+	return func(tx *Txn) error {
+		_, err := tx.Put([]byte(fmt.Sprintf("k%.3d", i)), data, false)
+		return err
+	}
+}
+
+func TestRaftReplication(t *testing.T) {
+	_, nodes := newCluster(3, 0)
+	defer stopCluster(nodes)
+
+	var rev int64
+	for i := 0; i < 20; i++ {
+		rev = update(t, nodes, putKey(i, i))
+	}
+	rev = update(t, nodes, func(tx *Txn) error {
+		tx.Delete([]byte("k000"))
+		_, err := tx.Put([]byte("k001"), 42, false)
+		return err
+	})
+	if rev != 22 {
+		t.Fatalf("raft: expected revision 22, have %d", rev)
+	}
+
+	for _, n := range nodes {
+		waitRev(t, n.DB(), rev)
+		testEqualDB(t, nodes[0].DB(), n.DB())
+	}
+
+	if _, err := nodes[0].DB().Txn().Put([]byte("k"), 1, false); err != ErrReadOnly {
+		t.Fatalf("raft: expected error %v, have %v", ErrReadOnly, err)
+	}
+}
+
+func TestRaftUpdateError(t *testing.T) {
+	_, nodes := newCluster(1, 0)
+	defer stopCluster(nodes)
+
+	update(t, nodes, putKey(0, 0))
+	_, err := waitLeader(t, nodes).Update(context.Background(), putKey(0, "string"))
+	if err != ErrIncompatibleValue {
+		t.Fatalf("raft: expected error %v, have %v", ErrIncompatibleValue, err)
+	}
+	if rev := nodes[0].DB().Rev(); rev != 1 {
+		t.Fatalf("raft: expected revision 1, have %d", rev)
+	}
+}
+
+func TestRaftFailover(t *testing.T) {
+	transport, nodes := newCluster(3, 0)
+	defer stopCluster(nodes)
+
+	update(t, nodes, putKey(0, 0))
+	lead := waitLeader(t, nodes)
+	transport.Disconnect(lead.ID())
+
+	rest := []*Node{}
+	for _, n := range nodes {
+		if n != lead {
+			rest = append(rest, n)
+		}
+	}
+	var rev int64
+	for i := 1; i < 10; i++ {
+		rev = update(t, rest, putKey(i, i))
+	}
+	if l := waitLeader(t, rest); l == lead {
+		t.Fatalf("raft: disconnected node is still the leader")
+	}
+
+	transport.Connect(lead.ID())
+	for _, n := range nodes {
+		waitRev(t, n.DB(), rev)
+		testEqualDB(t, rest[0].DB(), n.DB())
+	}
+}
+
+func TestRaftSnapshot(t *testing.T) {
+	transport, nodes := newCluster(3, 4)
+	defer stopCluster(nodes)
+
+	update(t, nodes, putKey(0, 0))
+	update(t, nodes, putKey(1, 0))
+	lead := waitLeader(t, nodes)
+	var lagging *Node
+	for _, n := range nodes {
+		if n != lead {
+			lagging = n
+			break
+		}
+	}
+	waitRev(t, lagging.DB(), 2)
+
+	w, _, err := lagging.DB().Watch([]byte("k000"))
+	if err != nil {
+		t.Fatalf("raft: watch: %v", err)
+	}
+	defer w.Cancel()
+
+	transport.Disconnect(lagging.ID())
+	rest := []*Node{}
+	for _, n := range nodes {
+		if n != lagging {
+			rest = append(rest, n)
+		}
+	}
+	var rev int64
+	for i := 0; i < 20; i++ {
+		rev = update(t, rest, putKey(i, i+1))
+	}
+
+	transport.Connect(lagging.ID())
+	waitRev(t, lagging.DB(), rev)
+	testEqualDB(t, lead.DB(), lagging.DB())
+
+	if ev := <-w.Recv(); ev.Err() != nil || ev.Data.(int) != 1 {
+		t.Fatalf("raft: expected update event, have %v %v", ev.Data, ev.Err())
+	}
+}
+
+func TestRaftBlockedReplica(t *testing.T) {
+	_, nodes := newCluster(3, 0)
+	defer stopCluster(nodes)
+
+	update(t, nodes, putKey(0, 0))
+	lead := waitLeader(t, nodes)
+	var replica *Node
+	for _, n := range nodes {
+		if n != lead {
+			replica = n
+			break
+		}
+	}
+	waitRev(t, replica.DB(), 1)
+
+	// The watcher never receives, the replica stops applying entries
+	// but keeps taking part in the cluster.
+	w, _, err := replica.DB().WatchWith([]byte("k000"), NotifierOptions{Policy: Block, Capacity: 1})
+	if err != nil {
+		t.Fatalf("raft: watch: %v", err)
+	}
+	var rev int64
+	for i := 0; i < 10; i++ {
+		rev = update(t, nodes, putKey(0, i+1))
+	}
+	if l := waitLeader(t, nodes); l != lead {
+		t.Fatalf("raft: expected leader %d, have %d", lead.ID(), l.ID())
+	}
+
+	w.Cancel()
+	waitRev(t, replica.DB(), rev)
+	testEqualDB(t, lead.DB(), replica.DB())
+}
+
+type recordTransport struct {
+	msgs []RaftMessage
+}
+
+func (t *recordTransport) Send(msg RaftMessage) { t.msgs = append(t.msgs, msg) }
+
+func (t *recordTransport) count(typ RaftMessageType) (n int) {
+	for _, msg := range t.msgs {
+		if msg.Type == typ {
+			n++
+		}
+	}
+	t.msgs = nil
+	return n
+}
+
+func TestRaftSnapshotOnce(t *testing.T) {
+	transport := &recordTransport{}
+	n := &Node{
+		id:           1,
+		peers:        []uint64{1, 2},
+		transport:    transport,
+		cfg:          RaftConfig{ElectionTicks: 10, HeartbeatTicks: 1},
+		db:           New(),
+		applyc:       make(chan struct{}, 1),
+		term:         1,
+		log:          []raftEntry{raftEntry{Index: 5, Term: 1}},
+		commit:       5,
+		last:         5,
+		snapshot:     raftSnapshot{Index: 5, Term: 1},
+		pending:      make(map[uint64]*proposal),
+		incarnations: make(map[uint64]uint64),
+	}
+	n.applied = sync.NewCond(&n.mu)
+	n.becomeLeader()
+	transport.count(MsgAppend)
+
+	// The follower lags behind the snapshot.
+	n.step(RaftMessage{Type: MsgAppendResp, From: 2, Term: 1, Reject: true})
+	if c := transport.count(MsgSnapshot); c != 1 {
+		t.Fatalf("raft: expected 1 snapshot, have %d", c)
+	}
+	for i := 0; i < n.cfg.ElectionTicks-1; i++ {
+		n.tick()
+		n.step(RaftMessage{Type: MsgAppendResp, From: 2, Term: 1, Reject: true})
+	}
+	if c := transport.count(MsgSnapshot); c != 0 {
+		t.Fatalf("raft: expected no snapshot while in flight, have %d", c)
+	}
+
+	// The snapshot is sent again after an election timeout.
+	n.tick()
+	if c := transport.count(MsgSnapshot); c != 1 {
+		t.Fatalf("raft: expected snapshot retry, have %d", c)
+	}
+
+	n.step(RaftMessage{Type: MsgAppendResp, From: 2, Term: 1, Match: 5})
+	if _, found := n.inflight[2]; found {
+		t.Fatalf("raft: acknowledged snapshot is still in flight")
+	}
+	n.tick()
+	if len(transport.msgs) != 1 || transport.msgs[0].Type != MsgAppend {
+		t.Fatalf("raft: expected append after catch-up, have %v", transport.msgs)
+	}
+}
+
+func TestRaftReusedID(t *testing.T) {
+	transport := &recordTransport{}
+	n := NewNode(RaftConfig{
+		ID:           1,
+		Peers:        []uint64{1, 2, 3},
+		Transport:    transport,
+		TickInterval: time.Hour,
+	})
+	defer n.Stop()
+
+	vote := func(term, incarnation uint64) {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		transport.msgs = nil
+		n.step(RaftMessage{Type: MsgVote, From: 2, Term: term, Incarnation: incarnation})
+	}
+
+	vote(1, 7)
+	if len(transport.msgs) != 1 || transport.msgs[0].Reject {
+		t.Fatalf("raft: expected granted vote, have %v", transport.msgs)
+	}
+
+	// Node 2 restarted and lost its vote.
+	vote(2, 8)
+	if len(transport.msgs) != 0 || n.term != 1 {
+		t.Fatalf("raft: expected message of reused ID to be ignored, have %v (term %d)", transport.msgs, n.term)
+	}
+}
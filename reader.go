@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+
+	"github.com/azmodb/llrb"
+)
+
+// Reader represents a consistent, read-only view of the database at a
+// single revision. Reads are not affected by transactions committed
+// after the view has been created. A Reader is safe for concurrent
+// use by multiple goroutines.
+type Reader struct {
+	tree *tree
+}
+
+// Reader returns a read-only view of the current revision of the
+// database.
+func (db *DB) Reader() *Reader { return &Reader{tree: db.load()} }
+
+// View executes fn with a read-only view of the current revision of
+// the database and returns the error returned by fn.
+func (db *DB) View(fn func(r *Reader) error) error {
+	return fn(&Reader{tree: db.load()})
+}
+
+// Rev returns the revision of the view.
+func (r *Reader) Rev() int64 { return r.tree.rev }
+
+// Get retrieves the value for a key at revision rev, see DB.Get. If
+// rev <= 0 it returns the value at the revision of the view.
+//
+// Get returns the revision of the key/value pair and an error if any.
+func (r *Reader) Get(key []byte, rev int64, equal bool) (interface{}, int64, error) {
+	return r.tree.get(key, rev, equal)
+}
+
+// Range iterates over values stored in the view, see DB.Range.
+func (r *Reader) Range(from, to []byte, rev int64, limit int32) (*Notifier, error) {
+	return r.tree.rangeNotify(context.Background(), from, to, rev, limit)
+}
+
+// Count returns the number of keys in the view over the interval
+// [from, to], see DB.Range for the from/to combinations.
+func (r *Reader) Count(from, to []byte) (int64, error) {
+	if from == nil && to == nil {
+		return r.tree.len, nil
+	}
+	count := int64(0)
+	err := r.tree.visit(from, to, func(_ *pair) bool {
+		count++
+		return false
+	})
+	return count, err
+}
+
+// Iterator returns an iterator over the values at revision rev in the
+// interval [from, to], see DB.Range for the from/to combinations. If
+// rev <= 0 the iterator returns the values at the revision of the
+// view. Keys without a value at rev are skipped.
+func (r *Reader) Iterator(from, to []byte, rev int64) (*Iterator, error) {
+	it := &Iterator{rev: rev}
+	err := r.tree.visit(from, to, func(p *pair) bool {
+		it.pairs = append(it.pairs, p)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// Scan calls fn for the values at revision rev of all keys greater
+// than or equal to from in key order, until fn returns true. If rev
+// <= 0 Scan uses the revision of the view. Keys without a value at rev
+// are skipped.
+//
+// Unlike Range, Scan visits only the keys following from, so a large
+// keyspace can be traversed in batches.
+func (r *Reader) Scan(from []byte, rev int64, fn func(key []byte, data interface{}, created int64) bool) {
+	lo := newMatcher(from)
+	defer lo.release()
+	r.tree.root.Range(lo, maxMatcher, func(elem llrb.Element) bool {
+		p := elem.(*pair)
+		b, found := lookup(p, rev, false)
+		return found && fn(p.key, b.Data, b.Rev)
+	})
+}
+
+// Iterator iterates over the key/value pairs of a view in key order.
+// An Iterator must not be used by multiple goroutines.
+type Iterator struct {
+	pairs []*pair
+	rev   int64
+	key   []byte
+	block block
+}
+
+// Next advances the iterator to the next key and reports whether
+// there is one.
+func (it *Iterator) Next() bool {
+	for len(it.pairs) > 0 {
+		p := it.pairs[0]
+		it.pairs = it.pairs[1:]
+		if b, found := lookup(p, it.rev, false); found {
+			it.key, it.block = p.key, b
+			return true
+		}
+	}
+	it.key, it.block = nil, block{}
+	return false
+}
+
+// Key returns the current key.
+func (it *Iterator) Key() []byte { return it.key }
+
+// Data returns the current value.
+func (it *Iterator) Data() interface{} { return it.block.Data }
+
+// Created returns the revision of the current value.
+func (it *Iterator) Created() int64 { return it.block.Rev }
+
+// visit calls fn for all pairs of the interval [from, to] in key order
+// until fn returns true.
+func (t *tree) visit(from, to []byte, fn func(p *pair) bool) error {
+	if to != nil && compare(from, to) > 0 {
+		return ErrInvertedRange
+	}
+	visitor := func(elem llrb.Element) bool { return fn(elem.(*pair)) }
+
+	switch {
+	case from == nil && to == nil:
+		t.root.ForEach(visitor)
+	case to == nil:
+		match := newMatcher(from)
+		defer match.release()
+		if elem := t.root.Get(match); elem != nil {
+			fn(elem.(*pair))
+		}
+	default:
+		lo, hi := newMatcher(from), newMatcher(to)
+		defer func() {
+			lo.release()
+			hi.release()
+		}()
+		t.root.Range(lo, hi, visitor)
+	}
+	return nil
+}
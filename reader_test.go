@@ -0,0 +1,107 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	db := New()
+	tx := db.Txn()
+	for i := 0; i < 10; i++ {
+		tx.Put([]byte(fmt.Sprintf("k%d", i)), i, false)
+	}
+	tx.Commit()
+
+	r := db.Reader()
+	tx = db.Txn()
+	tx.Put([]byte("k0"), 100, false)
+	tx.Delete([]byte("k1"))
+	tx.Put([]byte("k10"), 10, false)
+	tx.Commit()
+
+	wg := sync.WaitGroup{}
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if count, err := r.Count(nil, nil); err != nil || count != 10 {
+				t.Errorf("reader: expected 10 keys, have %d (%v)", count, err)
+			}
+			if data, _, err := r.Get([]byte("k0"), 0, false); err != nil || data.(int) != 0 {
+				t.Errorf("reader: expected value 0, have %v (%v)", data, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, test := range []struct {
+		from, to []byte
+		rev      int64
+		want     []string
+	}{
+		{nil, nil, 0, []string{"k0", "k1", "k2", "k3", "k4", "k5", "k6", "k7", "k8", "k9"}},
+		{[]byte("k2"), []byte("k5"), 0, []string{"k2", "k3", "k4"}},
+		{[]byte("k7"), nil, 0, []string{"k7"}},
+		{[]byte("x"), nil, 0, []string{}},
+		{nil, nil, 3, []string{"k0", "k1", "k2"}},
+	} {
+		it, err := r.Iterator(test.from, test.to, test.rev)
+		if err != nil {
+			t.Fatalf("iterator #%d: %v", i, err)
+		}
+		have := []string{}
+		for it.Next() {
+			if it.Data().(int) != int(it.Created())-1 {
+				t.Fatalf("iterator #%d: unexpected value %v at %d", i, it.Data(), it.Created())
+			}
+			have = append(have, string(it.Key()))
+		}
+		if fmt.Sprint(have) != fmt.Sprint(test.want) {
+			t.Fatalf("iterator #%d: expected %v, have %v", i, test.want, have)
+		}
+		if count, _ := r.Count(test.from, test.to); test.rev == 0 && count != int64(len(test.want)) {
+			t.Fatalf("count #%d: expected %d, have %d", i, len(test.want), count)
+		}
+	}
+
+	if _, err := r.Iterator([]byte("b"), []byte("a"), 0); err != ErrInvertedRange {
+		t.Fatalf("iterator: expected error %v, have %v", ErrInvertedRange, err)
+	}
+}
+
+func TestReaderScan(t *testing.T) {
+	db := New()
+	tx := db.Txn()
+	for _, key := range []string{"a", "b", "c", "\xff", "\xff\xff"} {
+		tx.Put([]byte(key), key, false)
+	}
+	tx.Commit()
+	tx = db.Txn()
+	tx.Put([]byte("b"), "b2", false)
+	tx.Commit()
+
+	for i, test := range []struct {
+		from  []byte
+		rev   int64
+		limit int
+		want  []string
+	}{
+		{nil, 0, 0, []string{"a", "b2", "c", "\xff", "\xff\xff"}},
+		{[]byte("b"), 0, 0, []string{"b2", "c", "\xff", "\xff\xff"}},
+		{[]byte("bb"), 0, 2, []string{"c", "\xff"}},
+		{[]byte("\xff\x00"), 0, 0, []string{"\xff\xff"}},
+		{nil, 3, 0, []string{"a", "b", "c"}},
+		{[]byte("z"), 0, 0, []string{"\xff", "\xff\xff"}},
+	} {
+		have := []string{}
+		db.Reader().Scan(test.from, test.rev, func(key []byte, data interface{}, created int64) bool {
+			have = append(have, data.(string))
+			return len(have) == test.limit
+		})
+		if fmt.Sprint(have) != fmt.Sprint(test.want) {
+			t.Fatalf("scan #%d: expected %q, have %q", i, test.want, have)
+		}
+	}
+}
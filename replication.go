@@ -0,0 +1,410 @@
+package db
+
+import (
+	"encoding/gob"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/azmodb/llrb"
+)
+
+const (
+	// ErrPrimaryClosed is returned when the primary has been closed.
+	ErrPrimaryClosed = perror("primary is shut down")
+
+	// ErrReplicaDiverged is returned when the follower state does not
+	// match the transactions streamed by the primary.
+	ErrReplicaDiverged = perror("replica diverged from primary")
+)
+
+// change represents a single key/value update of a committed
+// transaction.
+type change struct {
+	Key       []byte
+	Data      interface{}
+	Rev       int64
+	Tombstone bool
+	Deleted   bool
+
+	pair *pair // notified on commit
+}
+
+// record represents a committed transaction over the interval of
+// revisions [From, To].
+type record struct {
+	From    int64
+	To      int64
+	Changes []change
+}
+
+const (
+	msgRecord = iota + 1
+	msgSnapshotBegin
+	msgSnapshotPair
+	msgSnapshotEnd
+)
+
+// message represents a replication protocol message sent from the
+// primary to a follower.
+type message struct {
+	Kind   int
+	Rev    int64
+	Record record
+	Key    []byte
+	Blocks []block
+}
+
+// hello is sent by a follower to request all transactions committed
+// after Rev.
+type hello struct {
+	Rev int64
+}
+
+const defaultPrimaryLogSize = 1024
+
+// Primary streams committed transactions of a database, in revision
+// order, to any number of followers. Transactions are encoded using
+// encoding/gob, user defined value types must be registered.
+//
+// A Primary keeps a log of recent transactions. Followers which are
+// too far behind are caught up from a snapshot of the database.
+type Primary struct {
+	db     *DB
+	mu     sync.Mutex
+	cond   *sync.Cond
+	log    []record // recent committed transactions
+	size   int      // maximum number of logged transactions
+	base   int64    // log contains all transactions after base
+	last   int64    // revision of the last logged transaction
+	closed bool
+}
+
+// NewPrimary returns a primary streaming committed transactions of db.
+// Size is the maximum number of transactions kept in memory. If size
+// <= 0 a default size is used.
+func NewPrimary(db *DB, size int) *Primary {
+	if size <= 0 {
+		size = defaultPrimaryLogSize
+	}
+	p := &Primary{db: db, size: size}
+	p.cond = sync.NewCond(&p.mu)
+
+	db.writer.Lock()
+	p.base = db.Rev()
+	p.last = p.base
+	db.hooks = append(db.hooks, p)
+	db.writer.Unlock()
+	return p
+}
+
+// committed implements the observer interface.
+func (p *Primary) committed(rec record) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+
+	p.log = append(p.log, rec)
+	if n := len(p.log); n > p.size {
+		p.log = append(p.log[:0:0], p.log[n-p.size:]...)
+		p.base = p.log[0].From - 1
+	}
+	p.last = rec.To
+	p.cond.Broadcast()
+	p.mu.Unlock()
+}
+
+// replaced implements the observer interface. The replaced
+// transactions cannot be replayed, followers behind the new revision
+// are caught up from a snapshot.
+func (p *Primary) replaced(rec record) {
+	p.mu.Lock()
+	if !p.closed {
+		p.log = nil
+		p.base, p.last = rec.To, rec.To
+		p.cond.Broadcast()
+	}
+	p.mu.Unlock()
+}
+
+// Close stops streaming transactions. All running Serve calls return
+// ErrPrimaryClosed.
+func (p *Primary) Close() error {
+	p.db.writer.Lock()
+	p.db.removeHook(p)
+	p.db.writer.Unlock()
+
+	p.mu.Lock()
+	p.closed = true
+	p.log = nil
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	return nil
+}
+
+// next blocks until transactions committed after rev are available.
+// It returns the logged transactions or, if the transactions have
+// already been discarded, snapshot is true.
+func (p *Primary) next(rev int64, gone *error) (recs []record, snapshot bool, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		switch {
+		case p.closed:
+			return nil, false, ErrPrimaryClosed
+		case *gone != nil:
+			return nil, false, *gone
+		case rev < p.base:
+			return nil, true, nil
+		case rev > p.db.Rev():
+			return nil, false, ErrReplicaDiverged
+		}
+		if rev < p.last {
+			break
+		}
+		p.cond.Wait() // wait for the next committed transaction
+	}
+
+	for i, rec := range p.log {
+		if rec.From > rev {
+			recs = append(recs, p.log[i:]...)
+			break
+		}
+	}
+	return recs, false, nil
+}
+
+// Serve streams committed transactions to the follower connected to
+// rw. Serve blocks until the primary is closed or the connection fails.
+func (p *Primary) Serve(rw io.ReadWriter) error {
+	enc, dec := gob.NewEncoder(rw), gob.NewDecoder(rw)
+
+	var h hello
+	if err := dec.Decode(&h); err != nil {
+		return err
+	}
+
+	// The follower does not send any further messages. Wait for the
+	// connection to fail to stop waiting for new transactions.
+	var gone error
+	go func() {
+		var h hello
+		err := dec.Decode(&h)
+		if err == nil {
+			err = ErrReplicaDiverged
+		}
+		p.mu.Lock()
+		gone = err
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}()
+
+	rev := h.Rev
+	for {
+		recs, snapshot, err := p.next(rev, &gone)
+		if err != nil {
+			return err
+		}
+		if snapshot {
+			if rev, err = p.sendSnapshot(enc); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, rec := range recs {
+			if err = enc.Encode(&message{Kind: msgRecord, Record: rec}); err != nil {
+				return err
+			}
+			rev = rec.To
+		}
+	}
+}
+
+func (p *Primary) sendSnapshot(enc *gob.Encoder) (int64, error) {
+	tree := p.db.load()
+	if err := enc.Encode(&message{Kind: msgSnapshotBegin, Rev: tree.rev}); err != nil {
+		return 0, err
+	}
+
+	var err error
+	tree.root.ForEach(func(elem llrb.Element) bool {
+		pair := elem.(*pair)
+		err = enc.Encode(&message{
+			Kind:   msgSnapshotPair,
+			Key:    pair.key,
+			Blocks: pair.blocks,
+		})
+		return err != nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = enc.Encode(&message{Kind: msgSnapshotEnd, Rev: tree.rev})
+	return tree.rev, err
+}
+
+// Follower applies the transactions streamed by a primary to a
+// read-only database.
+type Follower struct {
+	db *DB
+}
+
+// NewFollower returns a follower replicating into a new, empty
+// read-only database.
+func NewFollower() *Follower {
+	db := New()
+	db.readOnly = true
+	return &Follower{db: db}
+}
+
+// DB returns the read-only database of the follower. Transactions on
+// the returned database reject all updates with ErrReadOnly.
+func (f *Follower) DB() *DB { return f.db }
+
+// Sync connects to the primary on rw and applies the streamed
+// transactions in revision order. Sync resumes at the current revision
+// of the follower database and blocks until the connection fails or
+// the primary is closed.
+func (f *Follower) Sync(rw io.ReadWriter) error {
+	enc, dec := gob.NewEncoder(rw), gob.NewDecoder(rw)
+	if err := enc.Encode(&hello{Rev: f.db.Rev()}); err != nil {
+		return err
+	}
+
+	var snap *treeBuilder
+	for {
+		var msg message
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+
+		switch msg.Kind {
+		case msgRecord:
+			if err := f.db.apply(msg.Record); err != nil {
+				return err
+			}
+		case msgSnapshotBegin:
+			snap = newTreeBuilder(msg.Rev)
+		case msgSnapshotPair:
+			if snap == nil {
+				return ErrReplicaDiverged
+			}
+			snap.insert(msg.Key, msg.Blocks)
+		case msgSnapshotEnd:
+			if snap == nil || snap.tree.rev != msg.Rev {
+				return ErrReplicaDiverged
+			}
+			f.db.replace(snap.commit())
+			snap = nil
+		default:
+			return ErrReplicaDiverged
+		}
+	}
+}
+
+// apply replays a committed transaction. The database must be at the
+// revision preceding the transaction.
+func (db *DB) apply(rec record) error {
+	tx := db.txn()
+	if tx.rev+1 != rec.From {
+		tx.Rollback()
+		return ErrReplicaDiverged
+	}
+	tx.single = rec.From == rec.To // replay single revision transactions
+	tx.replay = true
+
+	for _, c := range rec.Changes {
+		var rev int64
+		if c.Deleted {
+			rev = tx.Delete(c.Key)
+		} else {
+			var err error
+			if rev, err = tx.Put(c.Key, c.Data, c.Tombstone); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if rev != c.Rev {
+			tx.Rollback()
+			return ErrReplicaDiverged
+		}
+	}
+	return tx.Commit()
+}
+
+// treeBuilder builds a new tree from a snapshot.
+type treeBuilder struct {
+	tree *tree
+	txn  *llrb.Txn
+}
+
+func newTreeBuilder(rev int64) *treeBuilder {
+	t := &tree{root: &llrb.Tree{}, rev: rev}
+	return &treeBuilder{tree: t, txn: t.root.Txn()}
+}
+
+func (b *treeBuilder) insert(key []byte, blocks []block) {
+	b.txn.Insert(&pair{key: key, blocks: blocks, stream: &stream{}})
+	b.tree.len++
+}
+
+func (b *treeBuilder) commit() *tree {
+	b.tree.root = b.txn.Commit()
+	return b.tree
+}
+
+// replace replaces the database tree with t, which must not have been
+// published yet. Watchers of existing keys are retained and notified
+// if the key has been changed or deleted. Persistent watchers and
+// commit observers receive the net changes as a single transaction.
+func (db *DB) replace(t *tree) {
+	db.writer.Lock()
+	defer db.writer.Unlock()
+
+	old := db.load()
+	changes := []change{}
+	t.root.ForEach(func(elem llrb.Element) bool {
+		p := elem.(*pair)
+		if prev := old.root.Get(p); prev != nil {
+			prev := prev.(*pair)
+			p.stream = prev.stream
+			if prev.last().Rev == p.last().Rev {
+				return false
+			}
+		}
+		b := p.last()
+		changes = append(changes, change{Key: p.key, Data: b.Data, Rev: b.Rev, pair: p})
+		return false
+	})
+	old.root.ForEach(func(elem llrb.Element) bool {
+		p := elem.(*pair)
+		if t.root.Get(p) == nil { // the revision of the deletion is unknown
+			changes = append(changes, change{Key: p.key, Rev: t.rev, Deleted: true, pair: p})
+		}
+		return false
+	})
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Rev < changes[j].Rev })
+	db.store(t)
+
+	for _, c := range changes {
+		c.pair.stream.Notify(c.pair, t.rev)
+		if c.Deleted {
+			c.pair.stream.Cancel()
+		}
+		db.keys.Notify(c)
+	}
+	if t.rev > old.rev {
+		rec := record{From: old.rev + 1, To: t.rev, Changes: changes}
+		for _, o := range db.hooks {
+			o.replaced(rec)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func waitRev(t *testing.T, db *DB, rev int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for db.Rev() != rev {
+		if time.Now().After(deadline) {
+			t.Fatalf("replication: expected revision %d, have %d", rev, db.Rev())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func replicate(p *Primary, f *Follower) (func(), chan error) {
+	pc, fc := net.Pipe()
+	errc := make(chan error, 2)
+	go func() { errc <- p.Serve(pc) }()
+	go func() { errc <- f.Sync(fc) }()
+	return func() {
+		pc.Close()
+		fc.Close()
+		<-errc
+		<-errc
+	}, errc
+}
+
+func testEqualDB(t *testing.T, want, have *DB) {
+	w, _, _ := want.Range(nil, nil, 0, 0)
+	defer w.Cancel()
+	h, _, _ := have.Range(nil, nil, 0, 0)
+	defer h.Cancel()
+
+	for ev := range w.Recv() {
+		got := <-h.Recv()
+		if ev.Err() != nil {
+			if got.Err() == nil {
+				t.Fatalf("replication: unexpected key %q", got.Key)
+			}
+			break
+		}
+		if string(ev.Key) != string(got.Key) || ev.Data != got.Data ||
+			ev.Created != got.Created || ev.Current != got.Current {
+			t.Fatalf("replication: expected %q %v %d %d, have %q %v %d %d",
+				ev.Key, ev.Data, ev.Created, ev.Current,
+				got.Key, got.Data, got.Created, got.Current)
+		}
+	}
+}
+
+func TestReplication(t *testing.T) {
+	db := New()
+	tx := db.Txn()
+	for i := 0; i < 50; i++ {
+		tx.Put([]byte(fmt.Sprintf("k%.3d", i)), i, false)
+	}
+	tx.Commit()
+
+	p := NewPrimary(db, 0)
+	defer p.Close()
+	f := NewFollower()
+	stop, _ := replicate(p, f)
+	defer stop()
+	waitRev(t, f.DB(), db.Rev())
+
+	for i := 0; i < 10; i++ {
+		tx := db.Txn()
+		tx.Put([]byte(fmt.Sprintf("k%.3d", i)), i*10, false)
+		tx.Put([]byte(fmt.Sprintf("k%.3d", i+100)), i, true)
+		tx.Delete([]byte(fmt.Sprintf("k%.3d", i+20)))
+		tx.Commit()
+	}
+	waitRev(t, f.DB(), db.Rev())
+	testEqualDB(t, db, f.DB())
+
+	_, _, _, err := f.DB().Get([]byte("k001"), 2, true)
+	if err != nil {
+		t.Fatalf("replication: get k001 at revision 2: %v", err)
+	}
+}
+
+func TestReplicationCatchUp(t *testing.T) {
+	db := New()
+	p := NewPrimary(db, 2)
+	defer p.Close()
+	f := NewFollower()
+
+	tx := db.Txn()
+	tx.Put([]byte("deleted"), 0, false)
+	tx.Put([]byte("updated"), 0, false)
+	tx.Commit()
+
+	stop, _ := replicate(p, f)
+	waitRev(t, f.DB(), db.Rev())
+	stop()
+
+	deleted, _, err := f.DB().Watch([]byte("deleted"))
+	if err != nil {
+		t.Fatalf("replication: watch: %v", err)
+	}
+	defer deleted.Cancel()
+	updated, _, err := f.DB().Watch([]byte("updated"))
+	if err != nil {
+		t.Fatalf("replication: watch: %v", err)
+	}
+	defer updated.Cancel()
+	persistent, _, _ := f.DB().WatchWith([]byte("deleted"), NotifierOptions{Persistent: true})
+	defer persistent.Cancel()
+	feed, from := f.DB().Changefeed(context.Background(), NotifierOptions{})
+	defer feed.Cancel()
+	hooked := make(chan Commit, 1)
+	remove := f.DB().AddCommitHook(func(c Commit) { hooked <- c })
+	defer remove()
+
+	for i := 0; i < 10; i++ {
+		tx := db.Txn()
+		tx.Put([]byte(fmt.Sprintf("k%.3d", i)), i, false)
+		tx.Commit()
+	}
+	tx = db.Txn()
+	tx.Delete([]byte("deleted"))
+	tx.Put([]byte("updated"), 1, false)
+	tx.Commit()
+
+	stop, _ = replicate(p, f)
+	defer stop()
+	waitRev(t, f.DB(), db.Rev())
+	testEqualDB(t, db, f.DB())
+
+	if ev := <-updated.Recv(); ev.Err() != nil || ev.Data.(int) != 1 {
+		t.Fatalf("replication: expected update event, have %v %v", ev.Data, ev.Err())
+	}
+	<-deleted.Recv()
+	if ev := <-deleted.Recv(); ev.Err() != PairDeleted {
+		t.Fatalf("replication: expected error %v, have %v", PairDeleted, ev.Err())
+	}
+	if ev := <-persistent.Recv(); !ev.Deleted || ev.Current != db.Rev() {
+		t.Fatalf("replication: expected persistent delete event, have %+v", ev)
+	}
+
+	c, err := feed.Next(context.Background())
+	if err != nil || c.From != from+1 || c.To != db.Rev() || len(c.Changes) != 12 {
+		t.Fatalf("replication: unexpected catch up commit %+v (%v)", c, err)
+	}
+	for i, ch := range c.Changes {
+		if i > 0 && ch.Rev < c.Changes[i-1].Rev {
+			t.Fatalf("replication: changes not ordered by revision: %+v", c.Changes)
+		}
+	}
+	if last := c.Changes[11]; string(last.Key) != "deleted" || !last.Deleted {
+		t.Fatalf("replication: expected deletion of %q, have %+v", "deleted", last)
+	}
+	if c = <-hooked; c.To != db.Rev() || len(c.Changes) != 12 {
+		t.Fatalf("replication: unexpected catch up commit %+v", c)
+	}
+}
+
+func TestFollowerReadOnly(t *testing.T) {
+	f := NewFollower()
+	tx := f.DB().Txn()
+	defer tx.Rollback()
+
+	if _, err := tx.Put([]byte("k"), 1, false); err != ErrReadOnly {
+		t.Fatalf("follower: expected error %v, have %v", ErrReadOnly, err)
+	}
+	if rev := tx.Delete([]byte("k")); rev != 0 {
+		t.Fatalf("follower: expected revision 0, have %d", rev)
+	}
+	if err := tx.Commit(); err != ErrReadOnly {
+		t.Fatalf("follower: expected commit error %v, have %v", ErrReadOnly, err)
+	}
+}
+
+func TestPrimaryClose(t *testing.T) {
+	db := New()
+	p := NewPrimary(db, 0)
+	f := NewFollower()
+	_, errc := replicate(p, f)
+
+	p.Close()
+	if err := <-errc; err != ErrPrimaryClosed {
+		t.Fatalf("primary: expected error %v, have %v", ErrPrimaryClosed, err)
+	}
+	if len(db.hooks) != 0 {
+		t.Fatalf("primary: expected no commit observers, have %d", len(db.hooks))
+	}
+}
@@ -0,0 +1,158 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+)
+
+const (
+	maxBulkLen  = 512 << 20 // maximum bulk string length
+	maxArrayLen = 1 << 20   // maximum number of command arguments
+	maxInline   = 64 << 10  // maximum inline command length
+
+	// Announced lengths are not trusted for allocations, buffers
+	// beyond these sizes grow with the received data.
+	maxBulkAlloc  = 64 << 10
+	maxArrayAlloc = 1024
+)
+
+var errProtocol = errors.New("Protocol error")
+
+// readCommand reads a command sent as an array of bulk strings or as
+// an inline command.
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return bytes.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArrayLen {
+		return nil, errProtocol
+	}
+	args := make([][]byte, 0, min(n, maxArrayAlloc))
+	for i := 0; i < n; i++ {
+		line, err = readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errProtocol
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, errProtocol
+		}
+		arg, err := readBulk(r, size)
+		if err != nil {
+			return nil, err
+		}
+		if arg[size] != '\r' || arg[size+1] != '\n' {
+			return nil, errProtocol
+		}
+		args = append(args, arg[:size:size])
+	}
+	return args, nil
+}
+
+// readBulk reads a bulk string of size bytes followed by CRLF.
+func readBulk(r *bufio.Reader, size int) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(min(size+2, maxBulkAlloc))
+	if _, err := io.CopyN(&buf, r, int64(size+2)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		frag, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, frag...)
+		if len(line) > maxInline {
+			return nil, errProtocol
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+// writer encodes replies using the protocol version negotiated by the
+// client.
+type writer struct {
+	*bufio.Writer
+	proto int
+}
+
+func (w *writer) header(prefix byte, n int64) {
+	w.WriteByte(prefix)
+	w.WriteString(strconv.FormatInt(n, 10))
+	w.WriteString("\r\n")
+}
+
+func (w *writer) simple(s string) {
+	w.WriteByte('+')
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func (w *writer) error(s string) {
+	w.WriteByte('-')
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func (w *writer) integer(n int64) { w.header(':', n) }
+
+func (w *writer) bulk(b []byte) {
+	w.header('$', int64(len(b)))
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+func (w *writer) array(n int) { w.header('*', int64(n)) }
+
+// null writes a null bulk string in RESP2 or a null in RESP3.
+func (w *writer) null() {
+	if w.proto >= 3 {
+		w.WriteString("_\r\n")
+		return
+	}
+	w.WriteString("$-1\r\n")
+}
+
+// nullArray writes a null array in RESP2 or a null in RESP3.
+func (w *writer) nullArray() {
+	if w.proto >= 3 {
+		w.WriteString("_\r\n")
+		return
+	}
+	w.WriteString("*-1\r\n")
+}
+
+// mapHeader writes a map header in RESP3 or a flat array header of
+// key/value pairs in RESP2.
+func (w *writer) mapHeader(n int) {
+	if w.proto >= 3 {
+		w.header('%', int64(n))
+		return
+	}
+	w.array(2 * n)
+}
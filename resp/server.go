@@ -0,0 +1,852 @@
+// Package resp implements a Redis protocol (RESP2 and RESP3) front end
+// for the AzmoDB in-memory key/value database, so that redis-cli and
+// existing Redis client libraries can be used with a database.
+//
+// The following commands are supported:
+//
+//	GET key
+//	SET key value [EX seconds | PX milliseconds] [NX | XX]
+//	DEL key [key ...]
+//	MGET key [key ...]
+//	SCAN cursor [MATCH pattern] [COUNT count]
+//	INCR key
+//	EXPIRE key seconds
+//	TTL key
+//	MULTI, EXEC, DISCARD
+//	GETREV key rev
+//		returns the value of a key at a database revision
+//	REV
+//		returns the current database revision
+//
+// PING, ECHO, HELLO, SELECT 0, CLIENT, COMMAND and QUIT are accepted
+// for client compatibility.
+//
+// Values written by SET are stored as []byte. GET formats string,
+// integer and float values written by other front ends. Key expiry is
+// maintained by the server, expired keys are deleted from the
+// database in the background.
+//
+// Each write command and each EXEC runs in a single database
+// transaction. If the commit is rejected, for example by a validator,
+// the client receives the commit error instead of the command replies.
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azmodb/db"
+)
+
+const (
+	reapInterval = 100 * time.Millisecond
+	maxCursors   = 1024
+)
+
+// Server represents a Redis protocol server exposing a database.
+type Server struct {
+	db *db.DB
+
+	wmu sync.Mutex // serializes write transactions and their expiry updates
+
+	mu      sync.Mutex           // protects the fields below
+	expires map[string]time.Time // key deadlines
+	cursors map[uint64][]byte    // last key returned by a scan
+	order   []uint64             // cursors in creation order
+	cursor  uint64               // last assigned cursor
+	nextID  int64                // last assigned client id
+}
+
+// New returns a Redis protocol server exposing d.
+func New(d *db.DB) *Server {
+	return &Server{
+		db:      d,
+		expires: make(map[string]time.Time),
+		cursors: make(map[uint64][]byte),
+	}
+}
+
+// ListenAndServe listens on the TCP network address addr and serves
+// client connections.
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts connections on ln and serves each connection in a new
+// goroutine. Serve deletes expired keys while running and returns
+// when ln fails.
+func (s *Server) Serve(ln net.Listener) error {
+	done := make(chan struct{})
+	defer close(done)
+	go s.reaper(done)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn serves a single client connection and closes it.
+func (s *Server) ServeConn(nc net.Conn) {
+	defer nc.Close()
+
+	s.mu.Lock()
+	s.nextID++
+	c := &conn{
+		s:  s,
+		id: s.nextID,
+		r:  bufio.NewReader(nc),
+		w:  &writer{Writer: bufio.NewWriter(nc), proto: 2},
+	}
+	s.mu.Unlock()
+
+	for {
+		args, err := readCommand(c.r)
+		if err != nil {
+			if err == errProtocol {
+				c.w.error("ERR " + err.Error())
+				c.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := c.handle(args)
+		if c.r.Buffered() == 0 || quit { // flush after pipelined commands
+			if err = c.w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// command describes a database command. Commands with write set run
+// within a database transaction.
+type command struct {
+	fn    func(c *conn, tx *db.Txn, args [][]byte)
+	arity int // number of arguments, -n means at least n
+	write bool
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ping":   {fn: ping, arity: -1},
+		"echo":   {fn: echo, arity: 2},
+		"get":    {fn: get, arity: 2},
+		"mget":   {fn: mget, arity: -2},
+		"getrev": {fn: getrev, arity: 3},
+		"rev":    {fn: rev, arity: 1},
+		"ttl":    {fn: ttl, arity: 2},
+		"scan":   {fn: scan, arity: -2},
+		"set":    {fn: set, arity: -3, write: true},
+		"del":    {fn: del, arity: -2, write: true},
+		"incr":   {fn: incr, arity: 2, write: true},
+		"expire": {fn: expire, arity: 3, write: true},
+	}
+}
+
+type conn struct {
+	s     *Server
+	id    int64
+	r     *bufio.Reader
+	w     *writer
+	multi bool       // MULTI has been called
+	dirty bool       // a command could not be queued
+	queue [][][]byte // commands queued by MULTI
+
+	// pending holds the key deadlines set by the running write
+	// transaction, a zero deadline removes the expiry of a key.
+	pending map[string]time.Time
+}
+
+// handle executes a command and reports whether the connection must be
+// closed.
+func (c *conn) handle(args [][]byte) bool {
+	name := strings.ToLower(string(args[0]))
+	switch name {
+	case "quit":
+		c.w.simple("OK")
+		return true
+	case "hello":
+		c.hello(args)
+		return false
+	case "multi":
+		if c.multi {
+			c.w.error("ERR MULTI calls can not be nested")
+			return false
+		}
+		c.multi = true
+		c.w.simple("OK")
+		return false
+	case "exec":
+		c.exec()
+		return false
+	case "discard":
+		if !c.multi {
+			c.w.error("ERR DISCARD without MULTI")
+			return false
+		}
+		c.reset()
+		c.w.simple("OK")
+		return false
+	}
+
+	if c.multi {
+		if _, found := commands[name]; !found || !checkArity(name, args) {
+			c.dirty = true
+			c.unknown(name, args)
+			return false
+		}
+		c.queue = append(c.queue, args)
+		c.w.simple("QUEUED")
+		return false
+	}
+
+	switch name {
+	case "select":
+		if len(args) == 2 && string(args[1]) == "0" {
+			c.w.simple("OK")
+		} else {
+			c.w.error("ERR DB index is out of range")
+		}
+		return false
+	case "client":
+		c.w.simple("OK")
+		return false
+	case "command":
+		c.w.array(0)
+		return false
+	}
+
+	cmd, found := commands[name]
+	if !found || !checkArity(name, args) {
+		c.unknown(name, args)
+		return false
+	}
+	if !cmd.write {
+		cmd.fn(c, nil, args)
+		return false
+	}
+	c.write(func(tx *db.Txn) { cmd.fn(c, tx, args) })
+	return false
+}
+
+// write runs fn within a database transaction. The replies and expiry
+// changes of fn take effect only if the transaction commits, otherwise
+// the commit error is replied.
+func (c *conn) write(fn func(tx *db.Txn)) {
+	c.s.wmu.Lock()
+	defer c.s.wmu.Unlock()
+
+	var buf bytes.Buffer
+	out := c.w
+	c.w = &writer{Writer: bufio.NewWriter(&buf), proto: out.proto}
+	c.pending = make(map[string]time.Time)
+	tx := c.s.db.Txn()
+	fn(tx)
+	err := tx.Commit()
+	c.w.Flush()
+	pending := c.pending
+	c.w, c.pending = out, nil
+
+	if err != nil {
+		c.w.error("ERR " + err.Error())
+		return
+	}
+	c.s.updateExpiry(pending)
+	c.w.Write(buf.Bytes())
+}
+
+func checkArity(name string, args [][]byte) bool {
+	arity := commands[name].arity
+	if arity < 0 {
+		return len(args) >= -arity
+	}
+	return len(args) == arity
+}
+
+func (c *conn) unknown(name string, args [][]byte) {
+	if _, found := commands[name]; found {
+		c.w.error("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+	c.w.error("ERR unknown command '" + string(args[0]) + "'")
+}
+
+func (c *conn) reset() {
+	c.multi = false
+	c.dirty = false
+	c.queue = nil
+}
+
+// exec executes all queued commands within a single transaction.
+func (c *conn) exec() {
+	if !c.multi {
+		c.w.error("ERR EXEC without MULTI")
+		return
+	}
+	queue, dirty := c.queue, c.dirty
+	c.reset()
+	if dirty {
+		c.w.error("EXECABORT Transaction discarded because of previous errors.")
+		return
+	}
+
+	c.write(func(tx *db.Txn) {
+		c.w.array(len(queue))
+		for _, args := range queue {
+			commands[strings.ToLower(string(args[0]))].fn(c, tx, args)
+		}
+	})
+}
+
+func (c *conn) hello(args [][]byte) {
+	if len(args) > 1 {
+		proto, err := strconv.Atoi(string(args[1]))
+		if err != nil || proto < 2 || proto > 3 {
+			c.w.error("NOPROTO unsupported protocol version")
+			return
+		}
+		c.w.proto = proto
+	}
+
+	c.w.mapHeader(7)
+	c.w.bulk([]byte("server"))
+	c.w.bulk([]byte("azmodb"))
+	c.w.bulk([]byte("version"))
+	c.w.bulk([]byte("1.0.0"))
+	c.w.bulk([]byte("proto"))
+	c.w.integer(int64(c.w.proto))
+	c.w.bulk([]byte("id"))
+	c.w.integer(c.id)
+	c.w.bulk([]byte("mode"))
+	c.w.bulk([]byte("standalone"))
+	c.w.bulk([]byte("role"))
+	c.w.bulk([]byte("master"))
+	c.w.bulk([]byte("modules"))
+	c.w.array(0)
+}
+
+func ping(c *conn, tx *db.Txn, args [][]byte) {
+	if len(args) > 1 {
+		c.w.bulk(args[1])
+		return
+	}
+	c.w.simple("PONG")
+}
+
+func echo(c *conn, tx *db.Txn, args [][]byte) { c.w.bulk(args[1]) }
+
+// lookup returns the current value of a key. If tx is not nil the
+// value is read within the transaction.
+func (c *conn) lookup(tx *db.Txn, key []byte) (interface{}, error) {
+	if c.expired(key) {
+		return nil, db.ErrKeyNotFound
+	}
+	if tx != nil {
+		data, _, err := tx.Get(key)
+		return data, err
+	}
+	data, _, _, err := c.s.db.Get(key, 0, false)
+	return data, err
+}
+
+func (c *conn) value(data interface{}, err error) {
+	if err == db.ErrKeyNotFound || err == db.ErrRevisionNotFound {
+		c.w.null()
+		return
+	}
+	if err != nil {
+		c.w.error("ERR " + err.Error())
+		return
+	}
+	b, ok := format(data)
+	if !ok {
+		c.w.error(errWrongType)
+		return
+	}
+	c.w.bulk(b)
+}
+
+const (
+	errWrongType = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	errNotInt    = "ERR value is not an integer or out of range"
+	errOverflow  = "ERR increment or decrement would overflow"
+	errSyntax    = "ERR syntax error"
+)
+
+// format returns the string representation of a value.
+func format(data interface{}) ([]byte, bool) {
+	switch v := data.(type) {
+	case []byte:
+		return v, true
+	case string:
+		return []byte(v), true
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10), true
+	case int32:
+		return strconv.AppendInt(nil, int64(v), 10), true
+	case int64:
+		return strconv.AppendInt(nil, v, 10), true
+	case float32:
+		return strconv.AppendFloat(nil, float64(v), 'g', -1, 32), true
+	case float64:
+		return strconv.AppendFloat(nil, v, 'g', -1, 64), true
+	}
+	return nil, false
+}
+
+func get(c *conn, tx *db.Txn, args [][]byte) {
+	c.value(c.lookup(tx, args[1]))
+}
+
+func mget(c *conn, tx *db.Txn, args [][]byte) {
+	c.w.array(len(args) - 1)
+	for _, key := range args[1:] {
+		data, err := c.lookup(tx, key)
+		if _, ok := format(data); err != nil || !ok {
+			c.w.null()
+			continue
+		}
+		c.value(data, nil)
+	}
+}
+
+func getrev(c *conn, tx *db.Txn, args [][]byte) {
+	at, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil || at <= 0 {
+		c.w.error(errNotInt)
+		return
+	}
+	data, _, _, err := c.s.db.Get(args[1], at, false)
+	c.value(data, err)
+}
+
+func rev(c *conn, tx *db.Txn, args [][]byte) {
+	c.w.integer(c.s.db.Rev())
+}
+
+func set(c *conn, tx *db.Txn, args [][]byte) {
+	var expiry time.Duration
+	var nx, xx bool
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToLower(string(args[i])); {
+		case opt == "nx" && !xx:
+			nx = true
+		case opt == "xx" && !nx:
+			xx = true
+		case (opt == "ex" || opt == "px") && expiry == 0 && i+1 < len(args):
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil || n <= 0 {
+				c.w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			if expiry = time.Duration(n) * time.Millisecond; opt == "ex" {
+				expiry *= 1000
+			}
+			i++
+		default:
+			c.w.error(errSyntax)
+			return
+		}
+	}
+
+	key := args[1]
+	c.purge(tx, key)
+	_, _, err := tx.Get(key)
+	if (nx && err == nil) || (xx && err == db.ErrKeyNotFound) {
+		c.w.null()
+		return
+	}
+	if _, err = tx.Put(key, args[2], false); err != nil {
+		c.dbError(err)
+		return
+	}
+	c.setExpiry(key, expiry)
+	c.w.simple("OK")
+}
+
+func (c *conn) dbError(err error) {
+	if err == db.ErrIncompatibleValue {
+		c.w.error(errWrongType)
+		return
+	}
+	c.w.error("ERR " + err.Error())
+}
+
+func del(c *conn, tx *db.Txn, args [][]byte) {
+	n := int64(0)
+	for _, key := range args[1:] {
+		c.purge(tx, key)
+		if _, _, err := tx.Get(key); err == nil {
+			tx.Delete(key)
+			c.setExpiry(key, 0)
+			n++
+		}
+	}
+	c.w.integer(n)
+}
+
+func incr(c *conn, tx *db.Txn, args [][]byte) {
+	key := args[1]
+	c.purge(tx, key)
+	data, _, err := tx.Get(key)
+	if err == db.ErrKeyNotFound {
+		data, err = []byte("0"), nil
+	}
+	if err != nil {
+		c.dbError(err)
+		return
+	}
+
+	var n int64
+	max := int64(math.MaxInt64)
+	switch v := data.(type) {
+	case []byte:
+		n, err = strconv.ParseInt(string(v), 10, 64)
+	case string:
+		n, err = strconv.ParseInt(v, 10, 64)
+	case int:
+		n, max = int64(v), math.MaxInt
+	case int64:
+		n = v
+	default:
+		c.w.error(errWrongType)
+		return
+	}
+	if err != nil {
+		c.w.error(errNotInt)
+		return
+	}
+	if n == max {
+		c.w.error(errOverflow)
+		return
+	}
+
+	switch data.(type) {
+	case []byte:
+		data = strconv.AppendInt(nil, n+1, 10)
+	case string:
+		data = strconv.FormatInt(n+1, 10)
+	case int:
+		data = int(n + 1)
+	case int64:
+		data = n + 1
+	}
+	if _, err = tx.Put(key, data, false); err != nil {
+		c.dbError(err)
+		return
+	}
+	c.w.integer(n + 1)
+}
+
+func expire(c *conn, tx *db.Txn, args [][]byte) {
+	secs, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		c.w.error(errNotInt)
+		return
+	}
+
+	key := args[1]
+	c.purge(tx, key)
+	if _, _, err = tx.Get(key); err != nil {
+		c.w.integer(0)
+		return
+	}
+	if secs <= 0 {
+		tx.Delete(key)
+		c.setExpiry(key, 0)
+	} else {
+		c.setExpiry(key, time.Duration(secs)*time.Second)
+	}
+	c.w.integer(1)
+}
+
+func ttl(c *conn, tx *db.Txn, args [][]byte) {
+	key := args[1]
+	if _, err := c.lookup(tx, key); err != nil {
+		c.w.integer(-2)
+		return
+	}
+	deadline, found := c.deadline(key)
+	if !found {
+		c.w.integer(-1)
+		return
+	}
+	c.w.integer(int64((time.Until(deadline) + time.Second/2) / time.Second))
+}
+
+func scan(c *conn, tx *db.Txn, args [][]byte) {
+	cursor, err := strconv.ParseUint(string(args[1]), 10, 64)
+	if err != nil {
+		c.w.error("ERR invalid cursor")
+		return
+	}
+	var pattern []byte
+	count := 10
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToLower(string(args[i])); {
+		case opt == "match" && i+1 < len(args):
+			pattern = args[i+1]
+			i++
+		case opt == "count" && i+1 < len(args):
+			if count, err = strconv.Atoi(string(args[i+1])); err != nil || count < 1 {
+				c.w.error(errSyntax)
+				return
+			}
+			i++
+		default:
+			c.w.error(errSyntax)
+			return
+		}
+	}
+
+	var after []byte
+	if cursor != 0 {
+		var found bool
+		if after, found = c.s.loadCursor(cursor); !found {
+			c.w.error("ERR invalid cursor")
+			return
+		}
+	}
+
+	// Continue at the key of the cursor instead of skipping all keys
+	// up to it.
+	var keys [][]byte
+	var last []byte
+	scanned, done := 0, true
+	c.s.db.Reader().Scan(after, 0, func(key []byte, _ interface{}, _ int64) bool {
+		if after != nil && bytes.Equal(key, after) {
+			return false
+		}
+		if scanned == count {
+			done = false
+			return true
+		}
+		scanned++
+		last = key
+		if !c.expired(key) && (pattern == nil || match(pattern, key)) {
+			keys = append(keys, key)
+		}
+		return false
+	})
+
+	next := uint64(0)
+	if !done {
+		next = c.s.storeCursor(last)
+	}
+	c.w.array(2)
+	c.w.bulk(strconv.AppendUint(nil, next, 10))
+	c.w.array(len(keys))
+	for _, key := range keys {
+		c.w.bulk(key)
+	}
+}
+
+func (s *Server) loadCursor(cursor uint64) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, found := s.cursors[cursor]
+	return key, found
+}
+
+// storeCursor returns a new cursor continuing after key. Old cursors
+// are discarded once more than maxCursors are in use.
+func (s *Server) storeCursor(key []byte) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.order) == maxCursors {
+		delete(s.cursors, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.cursor++
+	s.cursors[s.cursor] = key
+	s.order = append(s.order, s.cursor)
+	return s.cursor
+}
+
+// setExpiry sets the time to live of a key once the running write
+// transaction commits. If ttl is 0 the key does not expire.
+func (c *conn) setExpiry(key []byte, ttl time.Duration) {
+	var deadline time.Time
+	if ttl > 0 {
+		deadline = time.Now().Add(ttl)
+	}
+	c.pending[string(key)] = deadline
+}
+
+// deadline returns the deadline of a key, including the changes of the
+// running write transaction.
+func (c *conn) deadline(key []byte) (time.Time, bool) {
+	if deadline, found := c.pending[string(key)]; found {
+		return deadline, !deadline.IsZero()
+	}
+	c.s.mu.Lock()
+	deadline, found := c.s.expires[string(key)]
+	c.s.mu.Unlock()
+	return deadline, found
+}
+
+func (c *conn) expired(key []byte) bool {
+	deadline, found := c.deadline(key)
+	return found && !time.Now().Before(deadline)
+}
+
+// purge deletes key within tx if it has expired.
+func (c *conn) purge(tx *db.Txn, key []byte) {
+	if c.expired(key) {
+		tx.Delete(key)
+		c.setExpiry(key, 0)
+	}
+}
+
+// updateExpiry applies the deadlines of a committed transaction.
+func (s *Server) updateExpiry(deadlines map[string]time.Time) {
+	s.mu.Lock()
+	for key, deadline := range deadlines {
+		if deadline.IsZero() {
+			delete(s.expires, key)
+		} else {
+			s.expires[key] = deadline
+		}
+	}
+	s.mu.Unlock()
+}
+
+func (s *Server) reaper(done <-chan struct{}) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.reap()
+		}
+	}
+}
+
+// reap deletes all expired keys. If the deletion is rejected, the keys
+// are retried later.
+func (s *Server) reap() {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	now := time.Now()
+	s.mu.Lock()
+	expired := make(map[string]time.Time)
+	for key, deadline := range s.expires {
+		if !now.Before(deadline) {
+			expired[key] = time.Time{}
+		}
+	}
+	s.mu.Unlock()
+	if len(expired) == 0 {
+		return
+	}
+
+	tx := s.db.Txn()
+	for key := range expired {
+		tx.Delete([]byte(key))
+	}
+	if tx.Commit() == nil {
+		s.updateExpiry(expired)
+	}
+}
+
+// match reports whether key matches the Redis glob-style pattern.
+func match(pattern, key []byte) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if match(pattern, key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			var ok bool
+			if pattern, ok = matchClass(pattern[1:], key[0]); !ok {
+				return false
+			}
+			key = key[1:]
+			continue
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(key) == 0 || key[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, key = pattern[1:], key[1:]
+	}
+	return len(key) == 0
+}
+
+// matchClass matches c against a character class and returns the
+// pattern following the class.
+func matchClass(pattern []byte, c byte) ([]byte, bool) {
+	negate := len(pattern) > 0 && pattern[0] == '^'
+	if negate {
+		pattern = pattern[1:]
+	}
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			matched = matched || pattern[1] == c
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (c >= lo && c <= hi)
+			pattern = pattern[3:]
+		default:
+			matched = matched || pattern[0] == c
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:] // skip ']'
+	}
+	return pattern, matched != negate
+}
@@ -0,0 +1,324 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azmodb/db"
+)
+
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newTestServer(t *testing.T) (*db.DB, *testClient, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	d := db.New()
+	go New(d).Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	c := &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+	return d, c, func() {
+		conn.Close()
+		ln.Close()
+	}
+}
+
+// do sends a command and returns the reply formatted like redis-cli.
+func (c *testClient) do(args ...string) string {
+	fmt.Fprintf(c.conn, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.conn, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return c.reply()
+}
+
+func (c *testClient) reply() string {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("reading reply: %v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+', '-', ':':
+		return line
+	case '_':
+		return "(nil)"
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return "(nil)"
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(c.r, buf); err != nil {
+			c.t.Fatalf("reading reply: %v", err)
+		}
+		return string(buf[:n])
+	case '*', '%':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return "(nil)"
+		}
+		if line[0] == '%' {
+			n *= 2
+		}
+		elems := make([]string, n)
+		for i := range elems {
+			elems[i] = c.reply()
+		}
+		return "[" + strings.Join(elems, " ") + "]"
+	}
+	c.t.Fatalf("unexpected reply %q", line)
+	return ""
+}
+
+func TestCommands(t *testing.T) {
+	_, c, shutdown := newTestServer(t)
+	defer shutdown()
+
+	for i, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PING"}, "+PONG"},
+		{[]string{"GET", "a"}, "(nil)"},
+		{[]string{"SET", "a", "1"}, "+OK"},
+		{[]string{"SET", "a", "2", "NX"}, "(nil)"},
+		{[]string{"SET", "b", "2", "XX"}, "(nil)"},
+		{[]string{"GET", "a"}, "1"},
+		{[]string{"INCR", "a"}, ":2"},
+		{[]string{"INCR", "c"}, ":1"},
+		{[]string{"SET", "s", "str"}, "+OK"},
+		{[]string{"INCR", "s"}, "-ERR value is not an integer or out of range"},
+		{[]string{"SET", "m", "9223372036854775807"}, "+OK"},
+		{[]string{"INCR", "m"}, "-ERR increment or decrement would overflow"},
+		{[]string{"GET", "m"}, "9223372036854775807"},
+		{[]string{"MGET", "a", "x", "c"}, "[2 (nil) 1]"},
+		{[]string{"REV"}, ":5"},
+		{[]string{"GETREV", "a", "1"}, "1"},
+		{[]string{"GETREV", "a", "3"}, "2"},
+		{[]string{"GETREV", "c", "1"}, "(nil)"},
+		{[]string{"DEL", "a", "x", "c"}, ":2"},
+		{[]string{"GET", "a"}, "(nil)"},
+		{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command"},
+		{[]string{"FOO"}, "-ERR unknown command 'FOO'"},
+		{[]string{"SET", "a", "1", "EX"}, "-ERR syntax error"},
+	} {
+		if have := c.do(test.args...); have != test.want {
+			t.Fatalf("commands #%d %v: expected %q, have %q", i, test.args, test.want, have)
+		}
+	}
+}
+
+func TestMultiExec(t *testing.T) {
+	d, c, shutdown := newTestServer(t)
+	defer shutdown()
+
+	for i, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SET", "a", "1"}, "+OK"},
+		{[]string{"MULTI"}, "+OK"},
+		{[]string{"INCR", "a"}, "+QUEUED"},
+		{[]string{"SET", "b", "x"}, "+QUEUED"},
+		{[]string{"GET", "a"}, "+QUEUED"},
+		{[]string{"EXEC"}, "[:2 +OK 2]"},
+		{[]string{"MULTI"}, "+OK"},
+		{[]string{"SET", "a", "5"}, "+QUEUED"},
+		{[]string{"DISCARD"}, "+OK"},
+		{[]string{"GET", "a"}, "2"},
+		{[]string{"MULTI"}, "+OK"},
+		{[]string{"SET", "a"}, "-ERR wrong number of arguments for 'set' command"},
+		{[]string{"EXEC"}, "-EXECABORT Transaction discarded because of previous errors."},
+		{[]string{"EXEC"}, "-ERR EXEC without MULTI"},
+	} {
+		if have := c.do(test.args...); have != test.want {
+			t.Fatalf("multi #%d %v: expected %q, have %q", i, test.args, test.want, have)
+		}
+	}
+
+	// The first transaction is committed as a single database
+	// transaction.
+	if _, created, _, err := d.Get([]byte("b"), 0, false); err != nil || created != 3 {
+		t.Fatalf("multi: expected revision 3, have %d (%v)", created, err)
+	}
+}
+
+func TestRejectedCommit(t *testing.T) {
+	d, c, shutdown := newTestServer(t)
+	defer shutdown()
+
+	remove := d.AddValidator(func(r *db.Reader, commit db.Commit) error {
+		for _, ch := range commit.Changes {
+			if string(ch.Key) == "locked" {
+				return errors.New("locked key")
+			}
+		}
+		return nil
+	})
+	defer remove()
+
+	for i, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SET", "a", "1"}, "+OK"},
+		{[]string{"SET", "locked", "1", "EX", "100"}, "-ERR locked key"},
+		{[]string{"GET", "locked"}, "(nil)"},
+		{[]string{"TTL", "locked"}, ":-2"},
+		{[]string{"INCR", "locked"}, "-ERR locked key"},
+		{[]string{"MULTI"}, "+OK"},
+		{[]string{"INCR", "a"}, "+QUEUED"},
+		{[]string{"EXPIRE", "a", "100"}, "+QUEUED"},
+		{[]string{"SET", "locked", "x"}, "+QUEUED"},
+		{[]string{"EXEC"}, "-ERR locked key"},
+		{[]string{"GET", "a"}, "1"},
+		{[]string{"TTL", "a"}, ":-1"},
+		{[]string{"EXPIRE", "a", "100"}, ":1"},
+		{[]string{"TTL", "a"}, ":100"},
+	} {
+		if have := c.do(test.args...); have != test.want {
+			t.Fatalf("reject #%d %v: expected %q, have %q", i, test.args, test.want, have)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	_, c, shutdown := newTestServer(t)
+	defer shutdown()
+
+	for i := 0; i < 25; i++ {
+		c.do("SET", fmt.Sprintf("k%.2d", i), "v")
+	}
+	c.do("SET", "other", "v")
+
+	keys, cursor := 0, "0"
+	for {
+		reply := c.do("SCAN", cursor, "MATCH", "k*", "COUNT", "7")
+		fields := strings.Fields(strings.Trim(reply, "[]"))
+		cursor = fields[0]
+		keys += len(fields) - 1
+		if cursor == "0" {
+			break
+		}
+	}
+	if keys != 25 {
+		t.Fatalf("scan: expected 25 keys, have %d", keys)
+	}
+
+	if have := c.do("SCAN", "12345"); have != "-ERR invalid cursor" {
+		t.Fatalf("scan: expected invalid cursor error, have %q", have)
+	}
+}
+
+func TestBulkAlloc(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	r := bufio.NewReader(strings.NewReader("*1000000\r\n$536870912\r\nshort"))
+	if _, err := readCommand(r); err != io.ErrUnexpectedEOF {
+		t.Fatalf("bulk: expected %v, have %v", io.ErrUnexpectedEOF, err)
+	}
+	runtime.ReadMemStats(&after)
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+		t.Fatalf("bulk: expected allocation below 1MB, have %d bytes", alloc)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	d, c, shutdown := newTestServer(t)
+	defer shutdown()
+
+	c.do("SET", "a", "1")
+	if have := c.do("TTL", "a"); have != ":-1" {
+		t.Fatalf("ttl: expected -1, have %q", have)
+	}
+	if have := c.do("EXPIRE", "a", "100"); have != ":1" {
+		t.Fatalf("expire: expected 1, have %q", have)
+	}
+	if have := c.do("TTL", "a"); have != ":100" {
+		t.Fatalf("ttl: expected 100, have %q", have)
+	}
+	if have := c.do("EXPIRE", "missing", "100"); have != ":0" {
+		t.Fatalf("expire: expected 0, have %q", have)
+	}
+
+	if have := c.do("SET", "b", "1", "PX", "50"); have != "+OK" {
+		t.Fatalf("set: expected OK, have %q", have)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if have := c.do("GET", "b"); have != "(nil)" {
+		t.Fatalf("get: expected expired key, have %q", have)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, _, _, err := d.Get([]byte("b"), 0, false); err == db.ErrKeyNotFound {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expire: expired key not deleted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHello(t *testing.T) {
+	_, c, shutdown := newTestServer(t)
+	defer shutdown()
+
+	if have := c.do("HELLO", "4"); have != "-NOPROTO unsupported protocol version" {
+		t.Fatalf("hello: expected NOPROTO, have %q", have)
+	}
+	want := "[server azmodb version 1.0.0 proto :3 id :1 mode standalone role master modules []]"
+	if have := c.do("HELLO", "3"); have != want {
+		t.Fatalf("hello: expected %q, have %q", want, have)
+	}
+	if have := c.do("GET", "missing"); have != "(nil)" {
+		t.Fatalf("get: expected nil, have %q", have)
+	}
+
+	// inline commands
+	fmt.Fprintf(c.conn, "PING hello\r\n")
+	if have := c.reply(); have != "hello" {
+		t.Fatalf("inline: expected hello, have %q", have)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for i, test := range []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"*", "", true},
+		{"k*", "key", true},
+		{"k*", "other", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h\\*llo", "h*llo", true},
+		{"*o*o", "foo", true},
+	} {
+		if have := match([]byte(test.pattern), []byte(test.key)); have != test.want {
+			t.Fatalf("match #%d (%q, %q): expected %v, have %v", i, test.pattern, test.key, test.want, have)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Package rpc implements a gRPC front end for the AzmoDB in-memory
+// key/value database. The service is defined in package rpcpb, the
+// matching Go client is implemented in package client.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/azmodb/db"
+	"github.com/azmodb/db/rpcpb"
+	"google.golang.org/grpc"
+)
+
+var errMissingValue = errors.New("rpc: missing or invalid value")
+
+// Server implements the rpcpb.DBServer interface on top of a database.
+type Server struct {
+	rpcpb.UnimplementedDBServer
+	db *db.DB
+}
+
+// NewServer returns a gRPC database service for d.
+func NewServer(d *db.DB) *Server { return &Server{db: d} }
+
+// Register registers the database service for d on s.
+func Register(s *grpc.Server, d *db.DB) {
+	rpcpb.RegisterDBServer(s, NewServer(d))
+}
+
+// Get implements the rpcpb.DBServer interface.
+func (s *Server) Get(ctx context.Context, req *rpcpb.GetRequest) (*rpcpb.GetResponse, error) {
+	data, created, current, err := s.db.Get(req.Key, req.Rev, req.Equal)
+	if err != nil {
+		return nil, rpcpb.Status(err)
+	}
+	value, err := rpcpb.NewValue(data)
+	if err != nil {
+		return nil, rpcpb.Status(err)
+	}
+	return &rpcpb.GetResponse{
+		Value:   value,
+		Created: created,
+		Current: current,
+	}, nil
+}
+
+// Range implements the rpcpb.DBServer interface.
+func (s *Server) Range(req *rpcpb.RangeRequest, stream rpcpb.DB_RangeServer) error {
+	ctx := stream.Context()
+	n, current, err := s.db.RangeContext(ctx, req.From, req.To, req.Rev, req.Limit)
+	if err != nil {
+		return rpcpb.Status(err)
+	}
+	defer n.Cancel()
+
+	if err = stream.Send(&rpcpb.RangeResponse{Current: current}); err != nil {
+		return err
+	}
+
+	// A request with from != nil and to == nil behaves like Get and
+	// delivers exactly one event.
+	single := req.From != nil && req.To == nil
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-n.Recv():
+			if !ok || ev.Err() == db.NotifierCanceled {
+				return nil
+			}
+			resp := &rpcpb.RangeResponse{Current: current, Event: newEvent(ev)}
+			if err = stream.Send(resp); err != nil {
+				return err
+			}
+			if single || ev.Err() != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// Rev implements the rpcpb.DBServer interface.
+func (s *Server) Rev(ctx context.Context, req *rpcpb.RevRequest) (*rpcpb.RevResponse, error) {
+	return &rpcpb.RevResponse{Rev: s.db.Rev()}, nil
+}
+
+// Watch implements the rpcpb.DBServer interface.
+func (s *Server) Watch(stream rpcpb.DB_WatchServer) error {
+	w := &watchStream{
+		stream:    stream,
+		notifiers: make(map[int64]*db.Notifier),
+	}
+	defer w.shutdown()
+
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		switch r := req.Request.(type) {
+		case *rpcpb.WatchRequest_Create:
+			err = w.create(s.db, r.Create)
+		case *rpcpb.WatchRequest_Cancel:
+			w.cancel(r.Cancel.WatchId)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// watchStream multiplexes the notifiers of a watch stream.
+type watchStream struct {
+	stream    rpcpb.DB_WatchServer
+	sendMu    sync.Mutex // serializes stream.Send
+	mu        sync.Mutex // protects notifiers
+	notifiers map[int64]*db.Notifier
+	wg        sync.WaitGroup
+}
+
+func (w *watchStream) send(resp *rpcpb.WatchResponse) error {
+	w.sendMu.Lock()
+	defer w.sendMu.Unlock()
+	return w.stream.Send(resp)
+}
+
+func (w *watchStream) create(d *db.DB, req *rpcpb.WatchCreate) error {
+	w.mu.Lock()
+	if _, found := w.notifiers[req.WatchId]; found {
+		w.mu.Unlock()
+		return w.send(&rpcpb.WatchResponse{
+			WatchId: req.WatchId,
+			Created: true,
+			Current: d.Rev(),
+			Error:   "rpc: duplicate watch id",
+		})
+	}
+
+	// forward blocks in Send while gRPC flow control waits for the
+	// client, so the queue fills up. The watcher is then ended with an
+	// ErrSlowConsumer event instead of stalling the database writers.
+	opts := db.NotifierOptions{Policy: db.CancelSlow}
+	n, current, err := d.WatchContext(w.stream.Context(), req.Key, opts)
+	if err != nil {
+		w.mu.Unlock()
+		return w.send(&rpcpb.WatchResponse{
+			WatchId: req.WatchId,
+			Created: true,
+			Current: current,
+			Error:   err.Error(),
+		})
+	}
+	w.notifiers[req.WatchId] = n
+	w.mu.Unlock()
+
+	if err = w.send(&rpcpb.WatchResponse{
+		WatchId: req.WatchId,
+		Created: true,
+		Current: current,
+	}); err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go w.forward(req.WatchId, n)
+	return nil
+}
+
+// forward sends the events of a notifier until the notifier has been
+// canceled or the key has been deleted.
+func (w *watchStream) forward(id int64, n *db.Notifier) {
+	defer w.wg.Done()
+	for ev := range n.Recv() {
+		err := w.send(&rpcpb.WatchResponse{WatchId: id, Event: newEvent(ev)})
+		if ev.Err() != nil || err != nil {
+			break
+		}
+	}
+
+	w.mu.Lock()
+	delete(w.notifiers, id)
+	w.mu.Unlock()
+	n.Cancel()
+}
+
+func (w *watchStream) cancel(id int64) {
+	w.mu.Lock()
+	n, found := w.notifiers[id]
+	w.mu.Unlock()
+	if found {
+		n.Cancel()
+	}
+}
+
+func (w *watchStream) shutdown() {
+	w.mu.Lock()
+	for _, n := range w.notifiers {
+		n.Cancel()
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+}
+
+// Txn implements the rpcpb.DBServer interface. The session holds the
+// database writer until the client commits, rolls back or the stream
+// ends.
+func (s *Server) Txn(stream rpcpb.DB_TxnServer) error {
+	tx, err := s.db.TxnContext(stream.Context())
+	if err != nil { // the client has gone away while waiting
+		return err
+	}
+	defer tx.Rollback()
+
+	rev := s.db.Rev()
+	if err := stream.Send(&rpcpb.TxnResponse{Rev: rev}); err != nil {
+		return err
+	}
+
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		resp := &rpcpb.TxnResponse{}
+		switch r := req.Request.(type) {
+		case *rpcpb.TxnRequest_Put:
+			if data := r.Put.Value.Data(); data == nil {
+				err = errMissingValue
+			} else {
+				rev, err = tx.Put(r.Put.Key, data, r.Put.Tombstone)
+			}
+		case *rpcpb.TxnRequest_Delete:
+			rev = tx.Delete(r.Delete.Key)
+		case *rpcpb.TxnRequest_Get:
+			var data interface{}
+			if data, resp.Created, err = tx.Get(r.Get.Key); err == nil {
+				resp.Value, err = rpcpb.NewValue(data)
+			}
+		case *rpcpb.TxnRequest_Commit:
+			if err = tx.Commit(); err != nil {
+				rev = s.db.Rev()
+			}
+			resp.Rev = rev
+			resp.Error = rpcpb.ErrorString(err)
+			return stream.Send(resp)
+		case *rpcpb.TxnRequest_Rollback:
+			tx.Rollback()
+			resp.Rev = s.db.Rev()
+			return stream.Send(resp)
+		}
+		resp.Rev = rev
+		resp.Error = rpcpb.ErrorString(err)
+		if err = stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func newEvent(ev db.Event) *rpcpb.Event {
+	if err := ev.Err(); err != nil {
+		return &rpcpb.Event{Error: err.Error()}
+	}
+	value, err := rpcpb.NewValue(ev.Data)
+	return &rpcpb.Event{
+		Key:     ev.Key,
+		Value:   value,
+		Created: ev.Created,
+		Current: ev.Current,
+		Error:   rpcpb.ErrorString(err),
+	}
+}
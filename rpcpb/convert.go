@@ -0,0 +1,202 @@
+// Package rpcpb contains the protocol buffer definitions of the AzmoDB
+// remote database API.
+package rpcpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative rpc.proto
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/azmodb/db"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NewValue returns the protocol buffer representation of data. Numeric
+// values keep their Go type, see NumberType.
+func NewValue(data interface{}) (*Value, error) {
+	switch v := data.(type) {
+	case []byte:
+		return &Value{Kind: &Value_BytesValue{BytesValue: v}}, nil
+	case string:
+		return &Value{Kind: &Value_StringValue{StringValue: v}}, nil
+	case bool:
+		return &Value{Kind: &Value_BoolValue{BoolValue: v}}, nil
+	case int:
+		return newInt(int64(v), NumberType_NUMBER_INT), nil
+	case int8:
+		return newInt(int64(v), NumberType_NUMBER_INT8), nil
+	case int16:
+		return newInt(int64(v), NumberType_NUMBER_INT16), nil
+	case int32:
+		return newInt(int64(v), NumberType_NUMBER_INT32), nil
+	case int64:
+		return newInt(v, NumberType_NUMBER_DEFAULT), nil
+	case uint:
+		return newUint(uint64(v), NumberType_NUMBER_UINT), nil
+	case uint8:
+		return newUint(uint64(v), NumberType_NUMBER_UINT8), nil
+	case uint16:
+		return newUint(uint64(v), NumberType_NUMBER_UINT16), nil
+	case uint32:
+		return newUint(uint64(v), NumberType_NUMBER_UINT32), nil
+	case uint64:
+		return newUint(v, NumberType_NUMBER_DEFAULT), nil
+	case float32:
+		return newFloat(float64(v), NumberType_NUMBER_FLOAT32), nil
+	case float64:
+		return newFloat(v, NumberType_NUMBER_DEFAULT), nil
+	}
+	return nil, fmt.Errorf("rpcpb: unsupported value type %T", data)
+}
+
+func newInt(v int64, t NumberType) *Value {
+	return &Value{Kind: &Value_IntValue{IntValue: v}, Type: t}
+}
+
+func newUint(v uint64, t NumberType) *Value {
+	return &Value{Kind: &Value_UintValue{UintValue: v}, Type: t}
+}
+
+func newFloat(v float64, t NumberType) *Value {
+	return &Value{Kind: &Value_FloatValue{FloatValue: v}, Type: t}
+}
+
+// Data returns the Go representation of v. It returns nil if v is
+// empty, or if its number type does not match its kind or cannot hold
+// its value.
+func (v *Value) Data() interface{} {
+	switch k := v.GetKind().(type) {
+	case *Value_BytesValue:
+		return k.BytesValue
+	case *Value_StringValue:
+		return k.StringValue
+	case *Value_BoolValue:
+		return k.BoolValue
+	case *Value_IntValue:
+		return intData(k.IntValue, v.GetType())
+	case *Value_UintValue:
+		return uintData(k.UintValue, v.GetType())
+	case *Value_FloatValue:
+		switch v.GetType() {
+		case NumberType_NUMBER_DEFAULT:
+			return k.FloatValue
+		case NumberType_NUMBER_FLOAT32:
+			return float32(k.FloatValue)
+		}
+	}
+	return nil
+}
+
+func intData(v int64, t NumberType) interface{} {
+	var data interface{}
+	switch t {
+	case NumberType_NUMBER_DEFAULT:
+		return v
+	case NumberType_NUMBER_INT:
+		if int64(int(v)) == v {
+			data = int(v)
+		}
+	case NumberType_NUMBER_INT8:
+		if int64(int8(v)) == v {
+			data = int8(v)
+		}
+	case NumberType_NUMBER_INT16:
+		if int64(int16(v)) == v {
+			data = int16(v)
+		}
+	case NumberType_NUMBER_INT32:
+		if int64(int32(v)) == v {
+			data = int32(v)
+		}
+	}
+	return data
+}
+
+func uintData(v uint64, t NumberType) interface{} {
+	var data interface{}
+	switch t {
+	case NumberType_NUMBER_DEFAULT:
+		return v
+	case NumberType_NUMBER_UINT:
+		if uint64(uint(v)) == v {
+			data = uint(v)
+		}
+	case NumberType_NUMBER_UINT8:
+		if uint64(uint8(v)) == v {
+			data = uint8(v)
+		}
+	case NumberType_NUMBER_UINT16:
+		if uint64(uint16(v)) == v {
+			data = uint16(v)
+		}
+	case NumberType_NUMBER_UINT32:
+		if uint64(uint32(v)) == v {
+			data = uint32(v)
+		}
+	}
+	return data
+}
+
+var knownErrors = map[error]codes.Code{
+	db.ErrRevisionNotFound:  codes.NotFound,
+	db.ErrKeyNotFound:       codes.NotFound,
+	db.ErrIncompatibleValue: codes.FailedPrecondition,
+	db.PairDeleted:          codes.NotFound,
+	db.NotifierCanceled:     codes.Canceled,
+	db.ErrInvertedRange:     codes.InvalidArgument,
+	db.ErrReadOnly:          codes.PermissionDenied,
+	db.ErrSlowConsumer:      codes.ResourceExhausted,
+}
+
+// ErrorString returns the message of err or an empty string if err is
+// nil.
+func ErrorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Error returns the database error with the given message. If the
+// message is empty, Error returns nil.
+func Error(msg string) error {
+	if msg == "" {
+		return nil
+	}
+	for err := range knownErrors {
+		if err.Error() == msg {
+			return err
+		}
+	}
+	return errors.New(msg)
+}
+
+// Status returns a gRPC status error for a database error. If err is
+// nil, Status returns nil.
+func Status(err error) error {
+	if err == nil {
+		return nil
+	}
+	code, found := knownErrors[err]
+	if !found {
+		code = codes.Unknown
+	}
+	return status.Error(code, err.Error())
+}
+
+// FromStatus returns the database error of a gRPC status error created
+// by Status. Other errors are returned unchanged.
+func FromStatus(err error) error {
+	s, ok := status.FromError(err)
+	if !ok || err == nil {
+		return err
+	}
+	for known, code := range knownErrors {
+		if s.Code() == code && s.Message() == known.Error() {
+			return known
+		}
+	}
+	return err
+}
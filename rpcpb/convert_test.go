@@ -0,0 +1,57 @@
+package rpcpb
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestValueRoundTrip(t *testing.T) {
+	for _, data := range []interface{}{
+		[]byte("bytes"),
+		"string",
+		true,
+		int(-1),
+		int8(math.MinInt8),
+		int16(math.MaxInt16),
+		int32(math.MinInt32),
+		int64(math.MaxInt64),
+		uint(math.MaxUint32),
+		uint8(math.MaxUint8),
+		uint16(math.MaxUint16),
+		uint32(math.MaxUint32),
+		uint64(math.MaxUint64),
+		float32(0.5),
+		float64(math.Pi),
+	} {
+		v, err := NewValue(data)
+		if err != nil {
+			t.Fatalf("value %T: %v", data, err)
+		}
+		have := v.Data()
+		if b, ok := data.([]byte); ok {
+			if !bytes.Equal(have.([]byte), b) {
+				t.Fatalf("value %T: expected %v, have %v", data, data, have)
+			}
+			continue
+		}
+		if have != data {
+			t.Fatalf("value %T: expected %T %v, have %T %v", data, data, data, have, have)
+		}
+	}
+
+	if _, err := NewValue(struct{}{}); err == nil {
+		t.Fatalf("value: expected unsupported type error")
+	}
+	for _, v := range []*Value{
+		newInt(math.MaxInt8+1, NumberType_NUMBER_INT8),
+		newUint(math.MaxUint16+1, NumberType_NUMBER_UINT16),
+		newInt(1, NumberType_NUMBER_UINT),
+		newFloat(1, NumberType_NUMBER_INT),
+		{},
+	} {
+		if data := v.Data(); data != nil {
+			t.Fatalf("value %v: expected <nil> data, have %T %v", v, data, data)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+// Protocol buffer definitions of the AzmoDB remote database API.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: rpc.proto
+
+package rpcpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DB_Get_FullMethodName   = "/azmodb.v1.DB/Get"
+	DB_Range_FullMethodName = "/azmodb.v1.DB/Range"
+	DB_Rev_FullMethodName   = "/azmodb.v1.DB/Rev"
+	DB_Watch_FullMethodName = "/azmodb.v1.DB/Watch"
+	DB_Txn_FullMethodName   = "/azmodb.v1.DB/Txn"
+)
+
+// DBClient is the client API for DB service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// DB is the remote interface of an AzmoDB database. Errors returned by
+// the database are reported with their message, see the database
+// package documentation.
+type DBClient interface {
+	// Get retrieves the value for a key at a revision.
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	// Range streams the key/value pairs in the interval [from, to]. The
+	// first response carries the current revision of the database only.
+	Range(ctx context.Context, in *RangeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RangeResponse], error)
+	// Rev returns the current revision of the database.
+	Rev(ctx context.Context, in *RevRequest, opts ...grpc.CallOption) (*RevResponse, error)
+	// Watch creates and cancels key watchers. The events of all watchers
+	// are multiplexed on the response stream.
+	Watch(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[WatchRequest, WatchResponse], error)
+	// Txn runs a transaction session. The first response is sent once
+	// the session holds the database writer. The transaction is rolled
+	// back if the stream ends before a commit request.
+	Txn(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[TxnRequest, TxnResponse], error)
+}
+
+type dBClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDBClient(cc grpc.ClientConnInterface) DBClient {
+	return &dBClient{cc}
+}
+
+func (c *dBClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, DB_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dBClient) Range(ctx context.Context, in *RangeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RangeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DB_ServiceDesc.Streams[0], DB_Range_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RangeRequest, RangeResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DB_RangeClient = grpc.ServerStreamingClient[RangeResponse]
+
+func (c *dBClient) Rev(ctx context.Context, in *RevRequest, opts ...grpc.CallOption) (*RevResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RevResponse)
+	err := c.cc.Invoke(ctx, DB_Rev_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dBClient) Watch(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[WatchRequest, WatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DB_ServiceDesc.Streams[1], DB_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, WatchResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DB_WatchClient = grpc.BidiStreamingClient[WatchRequest, WatchResponse]
+
+func (c *dBClient) Txn(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[TxnRequest, TxnResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DB_ServiceDesc.Streams[2], DB_Txn_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TxnRequest, TxnResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DB_TxnClient = grpc.BidiStreamingClient[TxnRequest, TxnResponse]
+
+// DBServer is the server API for DB service.
+// All implementations must embed UnimplementedDBServer
+// for forward compatibility.
+//
+// DB is the remote interface of an AzmoDB database. Errors returned by
+// the database are reported with their message, see the database
+// package documentation.
+type DBServer interface {
+	// Get retrieves the value for a key at a revision.
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	// Range streams the key/value pairs in the interval [from, to]. The
+	// first response carries the current revision of the database only.
+	Range(*RangeRequest, grpc.ServerStreamingServer[RangeResponse]) error
+	// Rev returns the current revision of the database.
+	Rev(context.Context, *RevRequest) (*RevResponse, error)
+	// Watch creates and cancels key watchers. The events of all watchers
+	// are multiplexed on the response stream.
+	Watch(grpc.BidiStreamingServer[WatchRequest, WatchResponse]) error
+	// Txn runs a transaction session. The first response is sent once
+	// the session holds the database writer. The transaction is rolled
+	// back if the stream ends before a commit request.
+	Txn(grpc.BidiStreamingServer[TxnRequest, TxnResponse]) error
+	mustEmbedUnimplementedDBServer()
+}
+
+// UnimplementedDBServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDBServer struct{}
+
+func (UnimplementedDBServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedDBServer) Range(*RangeRequest, grpc.ServerStreamingServer[RangeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Range not implemented")
+}
+func (UnimplementedDBServer) Rev(context.Context, *RevRequest) (*RevResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rev not implemented")
+}
+func (UnimplementedDBServer) Watch(grpc.BidiStreamingServer[WatchRequest, WatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedDBServer) Txn(grpc.BidiStreamingServer[TxnRequest, TxnResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Txn not implemented")
+}
+func (UnimplementedDBServer) mustEmbedUnimplementedDBServer() {}
+func (UnimplementedDBServer) testEmbeddedByValue()            {}
+
+// UnsafeDBServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DBServer will
+// result in compilation errors.
+type UnsafeDBServer interface {
+	mustEmbedUnimplementedDBServer()
+}
+
+func RegisterDBServer(s grpc.ServiceRegistrar, srv DBServer) {
+	// If the following call pancis, it indicates UnimplementedDBServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DB_ServiceDesc, srv)
+}
+
+func _DB_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DBServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DB_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DBServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DB_Range_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RangeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DBServer).Range(m, &grpc.GenericServerStream[RangeRequest, RangeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DB_RangeServer = grpc.ServerStreamingServer[RangeResponse]
+
+func _DB_Rev_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DBServer).Rev(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DB_Rev_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DBServer).Rev(ctx, req.(*RevRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DB_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DBServer).Watch(&grpc.GenericServerStream[WatchRequest, WatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DB_WatchServer = grpc.BidiStreamingServer[WatchRequest, WatchResponse]
+
+func _DB_Txn_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DBServer).Txn(&grpc.GenericServerStream[TxnRequest, TxnResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DB_TxnServer = grpc.BidiStreamingServer[TxnRequest, TxnResponse]
+
+// DB_ServiceDesc is the grpc.ServiceDesc for DB service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DB_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "azmodb.v1.DB",
+	HandlerType: (*DBServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _DB_Get_Handler,
+		},
+		{
+			MethodName: "Rev",
+			Handler:    _DB_Rev_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Range",
+			Handler:       _DB_Range_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _DB_Watch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Txn",
+			Handler:       _DB_Txn_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "rpc.proto",
+}
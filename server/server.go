@@ -0,0 +1,465 @@
+// Package server implements an HTTP/JSON front end for the AzmoDB
+// in-memory key/value database.
+//
+// The server exposes the following endpoints. Keys are passed as
+// escaped path segments or query parameters, values are arbitrary JSON
+// values.
+//
+//	GET    /v1/keys/<key>?rev=<rev>&equal=<bool>
+//		returns the value of a key at a revision
+//	PUT    /v1/keys/<key>?tombstone=<bool>
+//		sets the value of a key to the JSON request body
+//	DELETE /v1/keys/<key>
+//		removes a key
+//	GET    /v1/range?from=<key>&to=<key>&rev=<rev>&limit=<n>
+//		returns the keys in the interval [from, to]
+//	POST   /v1/txn
+//		applies a list of put and delete operations atomically
+//	GET    /v1/watch/<key>?rev=<rev>
+//		streams changes of a key as newline delimited JSON events,
+//		the stream ends if the client does not keep up
+//	GET    /v1/watch/<key>?rev=<rev>&wait=true
+//		long-polls the first change of a key after rev
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/azmodb/db"
+)
+
+// Pair represents a key/value pair returned by the server.
+type Pair struct {
+	Key     string      `json:"key"`
+	Value   interface{} `json:"value"`
+	Created int64       `json:"created"`
+	Current int64       `json:"current"`
+}
+
+// RangeResponse represents the response of a range request.
+type RangeResponse struct {
+	Pairs   []Pair `json:"pairs"`
+	Current int64  `json:"current"`
+}
+
+// Op represents a single operation of a transaction request.
+type Op struct {
+	Op        string      `json:"op"` // "put" or "delete"
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value,omitempty"`
+	Tombstone bool        `json:"tombstone,omitempty"`
+}
+
+// TxnRequest represents a transaction request.
+type TxnRequest struct {
+	Ops []Op `json:"ops"`
+}
+
+// TxnResponse represents the response of a transaction or a single
+// put or delete request.
+type TxnResponse struct {
+	Rev int64 `json:"rev"`
+}
+
+// Event represents a watch event. Deleted is set if the key has been
+// removed, no further events are sent.
+type Event struct {
+	Pair
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// Error represents an error response.
+type Error struct {
+	Error string `json:"error"`
+}
+
+const defaultPollTimeout = 30 * time.Second
+
+// Server represents an HTTP/JSON server exposing a database.
+type Server struct {
+	db  *db.DB
+	mux *http.ServeMux
+
+	// PollTimeout is the maximum duration a long-poll watch request
+	// waits for a change.
+	PollTimeout time.Duration
+}
+
+// New returns a server exposing db.
+func New(db *db.DB) *Server {
+	s := &Server{db: db, mux: http.NewServeMux(), PollTimeout: defaultPollTimeout}
+	s.mux.HandleFunc("/v1/keys/", s.handleKey)
+	s.mux.HandleFunc("/v1/range", s.handleRange)
+	s.mux.HandleFunc("/v1/txn", s.handleTxn)
+	s.mux.HandleFunc("/v1/watch/", s.handleWatch)
+	return s
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func statusCode(err error) int {
+	if _, ok := err.(*db.ValidationError); ok {
+		return http.StatusUnprocessableEntity
+	}
+	switch err {
+	case db.ErrKeyNotFound, db.ErrRevisionNotFound:
+		return http.StatusNotFound
+	case db.ErrIncompatibleValue:
+		return http.StatusConflict
+	case db.ErrInvertedRange:
+		return http.StatusBadRequest
+	case db.ErrReadOnly:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, Error{Error: err.Error()})
+}
+
+type badRequest string
+
+func (e badRequest) Error() string { return string(e) }
+
+// pathKey returns the unescaped key following prefix in the request
+// path.
+func pathKey(r *http.Request, prefix string) ([]byte, error) {
+	path := r.URL.EscapedPath()
+	if !strings.HasPrefix(path, prefix) || len(path) == len(prefix) {
+		return nil, badRequest("missing key")
+	}
+	key, err := url.PathUnescape(path[len(prefix):])
+	if err != nil {
+		return nil, badRequest("invalid key")
+	}
+	return []byte(key), nil
+}
+
+func queryInt(r *http.Request, name string) (int64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, badRequest("invalid " + name + " parameter")
+	}
+	return n, nil
+}
+
+func queryBool(r *http.Request, name string) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, badRequest("invalid " + name + " parameter")
+	}
+	return b, nil
+}
+
+func (s *Server) handleKey(w http.ResponseWriter, r *http.Request) {
+	key, err := pathKey(r, "/v1/keys/")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.get(w, r, key)
+	case "PUT":
+		var value interface{}
+		if err = json.NewDecoder(r.Body).Decode(&value); err != nil {
+			writeError(w, http.StatusBadRequest, badRequest("invalid value"))
+			return
+		}
+		tombstone, err := queryBool(r, "tombstone")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.txn(w, []Op{{Op: "put", Key: string(key), Value: value, Tombstone: tombstone}})
+	case "DELETE":
+		s.txn(w, []Op{{Op: "delete", Key: string(key)}})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, badRequest("method not allowed"))
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, key []byte) {
+	rev, err := queryInt(r, "rev")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	equal, err := queryBool(r, "equal")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	value, created, current, err := s.db.Get(key, rev, equal)
+	if err != nil {
+		writeError(w, statusCode(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, Pair{
+		Key:     string(key),
+		Value:   value,
+		Created: created,
+		Current: current,
+	})
+}
+
+func (s *Server) handleRange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, badRequest("method not allowed"))
+		return
+	}
+
+	var from, to []byte
+	q := r.URL.Query()
+	if _, found := q["from"]; found {
+		from = []byte(q.Get("from"))
+	}
+	if _, found := q["to"]; found {
+		to = []byte(q.Get("to"))
+	}
+	rev, err := queryInt(r, "rev")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := queryInt(r, "limit")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	n, current, err := s.db.RangeContext(r.Context(), from, to, rev, int32(limit))
+	if err != nil {
+		writeError(w, statusCode(err), err)
+		return
+	}
+	defer n.Cancel()
+
+	resp := RangeResponse{Pairs: []Pair{}, Current: current}
+	for ev := range n.Recv() {
+		if ev.Err() != nil {
+			break // canceled or the single requested key does not exist
+		}
+		resp.Pairs = append(resp.Pairs, newEvent(ev).Pair)
+		if from != nil && to == nil {
+			break // single key range request
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleTxn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, badRequest("method not allowed"))
+		return
+	}
+
+	var req TxnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, badRequest("invalid transaction"))
+		return
+	}
+	s.txn(w, req.Ops)
+}
+
+func (s *Server) txn(w http.ResponseWriter, ops []Op) {
+	for _, op := range ops {
+		if op.Op != "put" && op.Op != "delete" {
+			writeError(w, http.StatusBadRequest, badRequest("invalid operation "+op.Op))
+			return
+		}
+	}
+
+	tx := s.db.Txn()
+	rev := s.db.Rev()
+	for _, op := range ops {
+		var err error
+		switch op.Op {
+		case "put":
+			rev, err = tx.Put([]byte(op.Key), op.Value, op.Tombstone)
+		case "delete":
+			rev = tx.Delete([]byte(op.Key))
+		}
+		if err != nil {
+			tx.Rollback()
+			writeError(w, statusCode(err), err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, statusCode(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, TxnResponse{Rev: rev})
+}
+
+func (s *Server) handleWatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, badRequest("method not allowed"))
+		return
+	}
+	key, err := pathKey(r, "/v1/watch/")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	rev, err := queryInt(r, "rev")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	wait, err := queryBool(r, "wait")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// A client which stops reading must not stall the writers of the
+	// database, its watch is canceled instead.
+	opts := db.NotifierOptions{Policy: db.CancelSlow}
+	n, _, err := s.db.WatchContext(r.Context(), key, opts)
+	if err != nil {
+		writeError(w, statusCode(err), err)
+		return
+	}
+	defer n.Cancel()
+
+	// Changes committed after rev but before the watch has been
+	// registered are reported by the current value of the key.
+	var events []Event
+	value, created, current, err := s.db.Get(key, 0, false)
+	if err == nil && rev > 0 && created > rev {
+		events = append(events, newEvent(db.Event{
+			Key:     key,
+			Data:    value,
+			Created: created,
+			Current: current,
+		}))
+		rev = created
+	}
+
+	if wait {
+		s.poll(w, r, n, key, rev, events)
+		return
+	}
+	s.stream(w, r, n, key, rev, events)
+}
+
+func newEvent(ev db.Event) Event {
+	return Event{Pair: Pair{
+		Key:     string(ev.Key),
+		Value:   ev.Data,
+		Created: ev.Created,
+		Current: ev.Current,
+	}}
+}
+
+func (s *Server) poll(w http.ResponseWriter, r *http.Request, n *db.Notifier, key []byte, rev int64, events []Event) {
+	if len(events) > 0 {
+		writeJSON(w, http.StatusOK, events[0])
+		return
+	}
+
+	timeout := time.NewTimer(s.PollTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case ev, ok := <-n.Recv():
+			if !ok {
+				writeError(w, http.StatusServiceUnavailable, db.NotifierCanceled)
+				return
+			}
+			if err := ev.Err(); err != nil {
+				if err == db.PairDeleted {
+					writeJSON(w, http.StatusOK, Event{Pair: Pair{Key: string(key)}, Deleted: true})
+					return
+				}
+				writeError(w, http.StatusServiceUnavailable, err)
+				return
+			}
+			if ev.Created <= rev {
+				continue // already reported
+			}
+			writeJSON(w, http.StatusOK, newEvent(ev))
+			return
+		case <-timeout.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) stream(w http.ResponseWriter, r *http.Request, n *db.Notifier, key []byte, rev int64, events []Event) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	send := func(ev Event) bool {
+		if err := enc.Encode(ev); err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	for _, ev := range events {
+		if !send(ev) {
+			return
+		}
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case ev, ok := <-n.Recv():
+			if !ok {
+				return
+			}
+			if err := ev.Err(); err != nil { // deleted, canceled or slow consumer
+				if err == db.PairDeleted {
+					send(Event{Pair: Pair{Key: string(key)}, Deleted: true})
+				}
+				return
+			}
+			if ev.Created <= rev {
+				continue // already reported
+			}
+			if !send(newEvent(ev)) {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azmodb/db"
+)
+
+func do(t *testing.T, method, url string, body interface{}, code int, resp interface{}) {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("%s %s: %v", method, url, err)
+		}
+	}
+	req, err := http.NewRequest(method, url, &buf)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != code {
+		t.Fatalf("%s %s: expected status %d, have %d", method, url, code, r.StatusCode)
+	}
+	if resp != nil {
+		if err = json.NewDecoder(r.Body).Decode(resp); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, url, err)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	ts := httptest.NewServer(New(db.New()))
+	defer ts.Close()
+
+	var txn TxnResponse
+	do(t, "POST", ts.URL+"/v1/txn", TxnRequest{Ops: []Op{
+		{Op: "put", Key: "a", Value: 1},
+		{Op: "put", Key: "b", Value: "x"},
+		{Op: "put", Key: "c/d", Value: true},
+		{Op: "delete", Key: "missing"},
+	}}, http.StatusOK, &txn)
+	if txn.Rev != 3 {
+		t.Fatalf("txn: expected revision 3, have %d", txn.Rev)
+	}
+
+	do(t, "PUT", ts.URL+"/v1/keys/a", 2, http.StatusOK, &txn)
+	if txn.Rev != 4 {
+		t.Fatalf("put: expected revision 4, have %d", txn.Rev)
+	}
+	do(t, "PUT", ts.URL+"/v1/keys/a", "string", http.StatusConflict, nil)
+
+	var pair Pair
+	do(t, "GET", ts.URL+"/v1/keys/a", nil, http.StatusOK, &pair)
+	if pair.Value.(float64) != 2 || pair.Created != 4 || pair.Current != 4 {
+		t.Fatalf("get: unexpected pair %+v", pair)
+	}
+	do(t, "GET", ts.URL+"/v1/keys/a?rev=1&equal=true", nil, http.StatusOK, &pair)
+	if pair.Value.(float64) != 1 || pair.Created != 1 {
+		t.Fatalf("get: unexpected pair %+v", pair)
+	}
+	do(t, "GET", ts.URL+"/v1/keys/c%2Fd", nil, http.StatusOK, &pair)
+	if pair.Value.(bool) != true {
+		t.Fatalf("get: unexpected pair %+v", pair)
+	}
+
+	var e Error
+	do(t, "GET", ts.URL+"/v1/keys/missing", nil, http.StatusNotFound, &e)
+	if e.Error != db.ErrKeyNotFound.Error() {
+		t.Fatalf("get: expected error %q, have %q", db.ErrKeyNotFound, e.Error)
+	}
+	do(t, "GET", ts.URL+"/v1/keys/a?rev=2&equal=true", nil, http.StatusNotFound, &e)
+	if e.Error != db.ErrRevisionNotFound.Error() {
+		t.Fatalf("get: expected error %q, have %q", db.ErrRevisionNotFound, e.Error)
+	}
+
+	var rng RangeResponse
+	do(t, "GET", ts.URL+"/v1/range", nil, http.StatusOK, &rng)
+	if len(rng.Pairs) != 3 || rng.Current != 4 {
+		t.Fatalf("range: unexpected response %+v", rng)
+	}
+	do(t, "GET", ts.URL+"/v1/range?limit=2&rev=3", nil, http.StatusOK, &rng)
+	if len(rng.Pairs) != 2 || rng.Pairs[0].Key != "a" || rng.Pairs[0].Value.(float64) != 1 {
+		t.Fatalf("range: unexpected response %+v", rng)
+	}
+	do(t, "GET", ts.URL+"/v1/range?from=c&to=a", nil, http.StatusBadRequest, nil)
+	do(t, "GET", ts.URL+"/v1/range?from=b", nil, http.StatusOK, &rng)
+	if len(rng.Pairs) != 1 || rng.Pairs[0].Value.(string) != "x" {
+		t.Fatalf("range: unexpected response %+v", rng)
+	}
+
+	do(t, "DELETE", ts.URL+"/v1/keys/b", nil, http.StatusOK, &txn)
+	do(t, "GET", ts.URL+"/v1/keys/b", nil, http.StatusNotFound, nil)
+}
+
+func TestRejectedCommit(t *testing.T) {
+	d := db.New()
+	d.AddValidator(func(r *db.Reader, c db.Commit) error {
+		for _, ch := range c.Changes {
+			if ch.Key[0] == '_' {
+				return errors.New("reserved key")
+			}
+		}
+		return nil
+	})
+	ts := httptest.NewServer(New(d))
+	defer ts.Close()
+
+	var e Error
+	do(t, "PUT", ts.URL+"/v1/keys/_a", 1, http.StatusUnprocessableEntity, &e)
+	if e.Error != "reserved key" {
+		t.Fatalf("put: expected error %q, have %q", "reserved key", e.Error)
+	}
+	do(t, "POST", ts.URL+"/v1/txn", TxnRequest{Ops: []Op{
+		{Op: "put", Key: "a", Value: 1},
+		{Op: "put", Key: "_b", Value: 2},
+	}}, http.StatusUnprocessableEntity, nil)
+	do(t, "GET", ts.URL+"/v1/keys/a", nil, http.StatusNotFound, nil)
+}
+
+func TestWatchPoll(t *testing.T) {
+	d := db.New()
+	tx := d.Txn()
+	tx.Put([]byte("k"), 0, false)
+	tx.Commit()
+
+	s := New(d)
+	s.PollTimeout = 50 * time.Millisecond
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	do(t, "GET", ts.URL+"/v1/watch/k?wait=true&rev=1", nil, http.StatusNoContent, nil)
+
+	var ev Event
+	tx = d.Txn()
+	tx.Put([]byte("k"), 1, false)
+	tx.Commit()
+	do(t, "GET", ts.URL+"/v1/watch/k?wait=true&rev=1", nil, http.StatusOK, &ev)
+	if ev.Created != 2 || ev.Value.(float64) != 1 {
+		t.Fatalf("watch: unexpected event %+v", ev)
+	}
+
+	s.PollTimeout = 5 * time.Second
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		tx := d.Txn()
+		tx.Put([]byte("k"), 2, false)
+		tx.Commit()
+	}()
+	do(t, "GET", ts.URL+"/v1/watch/k?wait=true&rev=2", nil, http.StatusOK, &ev)
+	if ev.Created != 3 || ev.Value.(float64) != 2 {
+		t.Fatalf("watch: unexpected event %+v", ev)
+	}
+	do(t, "GET", ts.URL+"/v1/watch/missing?wait=true", nil, http.StatusNotFound, nil)
+}
+
+func TestWatchStream(t *testing.T) {
+	d := db.New()
+	tx := d.Txn()
+	tx.Put([]byte("k"), 0, false)
+	tx.Commit()
+
+	ts := httptest.NewServer(New(d))
+	defer ts.Close()
+
+	r, err := http.Get(ts.URL + "/v1/watch/k")
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	defer r.Body.Close()
+
+	go func() {
+		for i := 1; i <= 3; i++ {
+			tx := d.Txn()
+			tx.Put([]byte("k"), i, false)
+			tx.Commit()
+		}
+		tx := d.Txn()
+		tx.Delete([]byte("k"))
+		tx.Commit()
+	}()
+
+	events := []Event{}
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.NewDecoder(strings.NewReader(scanner.Text())).Decode(&ev); err != nil {
+			t.Fatalf("watch: decode event: %v", err)
+		}
+		events = append(events, ev)
+	}
+
+	if len(events) != 5 {
+		t.Fatalf("watch: expected 5 events, have %d: %+v", len(events), events)
+	}
+	for i, ev := range events[:3] {
+		if ev.Created != int64(i+2) || ev.Value.(float64) != float64(i+1) {
+			t.Fatalf("watch: unexpected event %+v", ev)
+		}
+	}
+	if !events[4].Deleted {
+		t.Fatalf("watch: expected delete event, have %+v", events[4])
+	}
+}
+
+func TestWatchSlowClient(t *testing.T) {
+	d := db.New()
+	tx := d.Txn()
+	tx.Put([]byte("k"), "", false)
+	tx.Commit()
+
+	ts := httptest.NewServer(New(d))
+	defer ts.Close()
+
+	r, err := http.Get(ts.URL + "/v1/watch/k")
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	defer r.Body.Close()
+
+	done := make(chan struct{})
+	go func() { // the watch stream is never read
+		value := strings.Repeat("x", 4096)
+		for i := 0; i < 4096; i++ {
+			tx := d.Txn()
+			tx.Put([]byte("k"), value, false)
+			tx.Commit()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("watch: writer blocked by a slow client")
+	}
+}
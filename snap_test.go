@@ -1,12 +1,14 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"reflect"
 	"testing"
 
 	"github.com/azmodb/db/backend"
+	"github.com/azmodb/db/backend/backendtest"
 )
 
 func TestEncodeDecode(t *testing.T) {
@@ -59,7 +61,7 @@ func TestBasicSnapshot(t *testing.T) {
 		os.RemoveAll("test_backend.db")
 	}()
 
-	if _, err := db.snapshot(b); err != nil {
+	if _, err := db.snapshot(context.Background(), b, SnapshotOptions{}); err != nil {
 		t.Fatalf("basic snapshot: %v", err)
 	}
 
@@ -85,3 +87,240 @@ func TestBasicSnapshot(t *testing.T) {
 		t.Fatalf("basic snapshot: expected %d pairs, have %d", count, i)
 	}
 }
+
+func testSnapshotFault(t *testing.T, fault backendtest.Fault) {
+	b, err := backend.Open("test_fault_backend.db", 0)
+	if err != nil {
+		t.Fatalf("open backend: %v", err)
+	}
+	defer func() {
+		b.Close()
+		os.RemoveAll("test_fault_backend.db")
+	}()
+
+	count := 100
+	db := New()
+	tx := db.Txn()
+	for i := 0; i < count; i++ {
+		key := []byte(fmt.Sprintf("k%.3d", i))
+		tx.Put(key, i, false)
+	}
+	tx.Commit()
+	if _, err = db.snapshot(context.Background(), b, SnapshotOptions{}); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+
+	tx = db.Txn()
+	for i := 0; i < count+10; i++ {
+		key := []byte(fmt.Sprintf("k%.3d", i))
+		tx.Put(key, -i, false)
+	}
+	tx.Commit()
+	if _, err = db.snapshot(context.Background(), backendtest.NewFaulty(b, fault), SnapshotOptions{}); err == nil {
+		t.Fatalf("snapshot %+v: expected error, have <nil>", fault)
+	}
+
+	ndb, err := reload(b)
+	if err != nil {
+		t.Fatalf("reload %+v: %v", fault, err)
+	}
+	if rev := ndb.Rev(); rev != int64(count) {
+		t.Fatalf("reload %+v: expected revision %d, have %d", fault, count, rev)
+	}
+	testForEach(t, ndb, 0, count)
+
+	_, err = reload(backendtest.NewFaulty(b, backendtest.Fault{Range: count / 2}))
+	if err != backendtest.ErrInjected {
+		t.Fatalf("reload: expected error %v, have %v", backendtest.ErrInjected, err)
+	}
+}
+
+func TestSnapshotFaults(t *testing.T) {
+	for _, fault := range []backendtest.Fault{
+		{Put: 1},
+		{Put: 50},
+		{Put: 110},
+		{Close: true},
+		{Put: 1, Crash: true},
+		{Put: 50, Crash: true},
+		{Put: 110, Crash: true},
+		{Close: true, Crash: true},
+	} {
+		testSnapshotFault(t, fault)
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	db, err := Load("test_empty_backend.db", 0)
+	if err != nil {
+		t.Fatalf("load empty backend: %v", err)
+	}
+	defer os.RemoveAll("test_empty_backend.db")
+
+	if rev := db.Rev(); rev != 0 {
+		t.Fatalf("load empty backend: expected revision 0, have %d", rev)
+	}
+}
+
+func TestSnapshotIdempotent(t *testing.T) {
+	db, err := Load("test_idempotent_backend.db", 0)
+	if err != nil {
+		t.Fatalf("load backend: %v", err)
+	}
+	defer os.RemoveAll("test_idempotent_backend.db")
+
+	if _, written, err := db.Snapshot(); err != nil || written {
+		t.Fatalf("snapshot: expected no-op on empty database, have %v %v", written, err)
+	}
+
+	put := func(count int) {
+		tx := db.Txn()
+		for i := 0; i < count; i++ {
+			key := []byte(fmt.Sprintf("k%.3d", i))
+			tx.Put(key, i, false)
+		}
+		tx.Commit()
+	}
+
+	put(10)
+	rev, written, err := db.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if !written || rev != 10 {
+		t.Fatalf("snapshot: expected revision 10 written, have %d %v", rev, written)
+	}
+
+	rev, written, err = db.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot: unchanged revision: %v", err)
+	}
+	if written || rev != 10 {
+		t.Fatalf("snapshot: expected no-op at revision 10, have %d %v", rev, written)
+	}
+
+	put(10)
+	count := 8
+	results := make(chan bool, count)
+	for i := 0; i < count; i++ {
+		go func() {
+			_, written, err := db.Snapshot()
+			if err != nil {
+				written = false
+				t.Errorf("concurrent snapshot: %v", err)
+			}
+			results <- written
+		}()
+	}
+
+	n := 0
+	for i := 0; i < count; i++ {
+		if <-results {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Fatalf("concurrent snapshot: expected one snapshot written, have %d", n)
+	}
+}
+
+func TestSnapshotContext(t *testing.T) {
+	db, err := Load("test_context_backend.db", 0)
+	if err != nil {
+		t.Fatalf("load backend: %v", err)
+	}
+	defer os.RemoveAll("test_context_backend.db")
+
+	count := 100
+	tx := db.Txn()
+	for i := 0; i < count; i++ {
+		key := []byte(fmt.Sprintf("k%.3d", i))
+		tx.Put(key, i, false)
+	}
+	tx.Commit()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, written, err := db.SnapshotContext(ctx, SnapshotOptions{
+		Interval: 10,
+		Progress: func(p SnapshotProgress) {
+			if p.Pairs == 50 {
+				cancel()
+			}
+		},
+	})
+	if err != context.Canceled || written {
+		t.Fatalf("snapshot: expected error %v, have %v %v", context.Canceled, written, err)
+	}
+	if rev, _ := db.backend.Last(); rev != zeroRevision {
+		t.Fatalf("snapshot: canceled snapshot is visible at %v", rev)
+	}
+
+	reports := []SnapshotProgress{}
+	rev, written, err := db.SnapshotContext(context.Background(), SnapshotOptions{
+		Interval: 30,
+		Progress: func(p SnapshotProgress) { reports = append(reports, p) },
+	})
+	if err != nil || !written || rev != int64(count) {
+		t.Fatalf("snapshot: expected revision %d written, have %d %v %v", count, rev, written, err)
+	}
+
+	want := []int64{30, 60, 90, 100}
+	if len(reports) != len(want) {
+		t.Fatalf("snapshot: expected %d progress reports, have %d", len(want), len(reports))
+	}
+	for i, p := range reports {
+		if p.Pairs != want[i] || p.Total != int64(count) || p.Bytes <= 0 {
+			t.Fatalf("snapshot: unexpected progress report #%d: %+v", i, p)
+		}
+	}
+}
+
+func TestSnapshotCanceled(t *testing.T) {
+	b, err := backend.Open("test_canceled_backend.db", 0)
+	if err != nil {
+		t.Fatalf("open backend: %v", err)
+	}
+	defer os.RemoveAll("test_canceled_backend.db")
+
+	count := 100
+	db := New()
+	db.backend = b
+	tx := db.Txn()
+	for i := 0; i < count; i++ {
+		key := []byte(fmt.Sprintf("k%.3d", i))
+		tx.Put(key, i, false)
+	}
+	tx.Commit()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, written, err := db.SnapshotContext(ctx, SnapshotOptions{
+		Interval: 10,
+		Progress: func(p SnapshotProgress) {
+			if p.Pairs == 50 {
+				cancel()
+			}
+		},
+	})
+	if err != context.Canceled || written {
+		t.Fatalf("snapshot: expected error %v, have %v %v", context.Canceled, written, err)
+	}
+
+	revs, err := b.Revisions()
+	if err != nil {
+		t.Fatalf("revisions: %v", err)
+	}
+	if len(revs) != 0 {
+		t.Fatalf("snapshot: canceled snapshot is visible at %v", revs)
+	}
+	if err = b.Close(); err != nil {
+		t.Fatalf("close backend: %v", err)
+	}
+
+	ndb, err := Load("test_canceled_backend.db", 0)
+	if err != nil {
+		t.Fatalf("load backend: %v", err)
+	}
+	if rev := ndb.Rev(); rev != 0 {
+		t.Fatalf("load: expected revision 0, have %d", rev)
+	}
+}
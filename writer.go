@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// WriterInfo describes the holder of the exclusive writer lock.
+type WriterInfo struct {
+	Stack    []byte        // stack trace of the lock acquisition
+	Acquired time.Time     // time of the lock acquisition
+	Held     time.Duration // duration the lock has been held
+}
+
+// writerLock is the exclusive writer lock of a database. Unlike a
+// sync.Mutex, waiting for the lock can be abandoned.
+type writerLock struct {
+	sem chan struct{}
+
+	mu        sync.Mutex // protects the debug state below
+	threshold time.Duration
+	report    func(WriterInfo)
+	holder    *WriterInfo
+	timer     *time.Timer
+}
+
+func newWriterLock() *writerLock {
+	return &writerLock{sem: make(chan struct{}, 1)}
+}
+
+func (l *writerLock) Lock() {
+	l.sem <- struct{}{}
+	l.acquired()
+}
+
+// TryLock acquires the lock if it is free and reports whether it has
+// been acquired.
+func (l *writerLock) TryLock() bool {
+	select {
+	case l.sem <- struct{}{}:
+		l.acquired()
+		return true
+	default:
+		return false
+	}
+}
+
+// LockContext acquires the lock or returns the context error if ctx
+// is done first.
+func (l *writerLock) LockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case l.sem <- struct{}{}:
+		l.acquired()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *writerLock) Unlock() {
+	l.mu.Lock()
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	l.holder = nil
+	l.mu.Unlock()
+	<-l.sem
+}
+
+// acquired records the new holder of the lock if debugging is enabled.
+func (l *writerLock) acquired() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.threshold <= 0 {
+		return
+	}
+
+	holder := &WriterInfo{Stack: debug.Stack(), Acquired: time.Now()}
+	l.holder = holder
+	l.timer = time.AfterFunc(l.threshold, func() {
+		l.mu.Lock()
+		report := l.report
+		held := l.holder == holder
+		l.mu.Unlock()
+		if held {
+			info := *holder
+			info.Held = time.Since(info.Acquired)
+			report(info)
+		}
+	})
+}
+
+// DebugWriter records the stack of every writer transaction and calls
+// report for each transaction holding the writer lock longer than
+// threshold. If report is nil, the transaction is logged. If threshold
+// <= 0 debugging is disabled.
+func (db *DB) DebugWriter(threshold time.Duration, report func(WriterInfo)) {
+	if report == nil {
+		report = func(info WriterInfo) {
+			log.Printf("db: writer lock held for %v, acquired at:\n%s", info.Held, info.Stack)
+		}
+	}
+	l := db.writer
+	l.mu.Lock()
+	l.threshold = threshold
+	l.report = report
+	l.mu.Unlock()
+}
+
+// Writer returns the current holder of the writer lock. It reports
+// false if the lock is free or debugging is disabled.
+func (db *DB) Writer() (WriterInfo, bool) {
+	l := db.writer
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holder == nil {
+		return WriterInfo{}, false
+	}
+	info := *l.holder
+	info.Held = time.Since(info.Acquired)
+	return info, true
+}
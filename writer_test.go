@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTryTxn(t *testing.T) {
+	db := New()
+	tx, ok := db.TryTxn()
+	if !ok {
+		t.Fatalf("try txn: expected free writer lock")
+	}
+	if _, ok = db.TryTxn(); ok {
+		t.Fatalf("try txn: expected held writer lock")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := db.TxnContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("txn context: expected error %v, have %v", context.DeadlineExceeded, err)
+	}
+
+	tx.Put([]byte("k"), 1, false)
+	tx.Commit()
+	if tx, err := db.TxnContext(context.Background()); err != nil {
+		t.Fatalf("txn context: %v", err)
+	} else {
+		tx.Rollback()
+	}
+	if db.Rev() != 1 {
+		t.Fatalf("try txn: expected revision 1, have %d", db.Rev())
+	}
+}
+
+func TestDebugWriter(t *testing.T) {
+	db := New()
+	reports := make(chan WriterInfo, 1)
+	db.DebugWriter(10*time.Millisecond, func(info WriterInfo) { reports <- info })
+
+	tx := db.Txn()
+	info, ok := db.Writer()
+	if !ok || !strings.Contains(string(info.Stack), "TestDebugWriter") {
+		t.Fatalf("debug writer: expected writer stack, have %v %s", ok, info.Stack)
+	}
+
+	select {
+	case info = <-reports:
+		if info.Held < 10*time.Millisecond {
+			t.Fatalf("debug writer: expected held >= 10ms, have %v", info.Held)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("debug writer: expected long transaction report")
+	}
+	tx.Rollback()
+
+	if _, ok = db.Writer(); ok {
+		t.Fatalf("debug writer: expected free writer lock")
+	}
+	tx = db.Txn() // short transactions are not reported
+	tx.Rollback()
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case info = <-reports:
+		t.Fatalf("debug writer: unexpected report %v", info.Held)
+	default:
+	}
+}